@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fooxytv/verbose/internal/session"
+)
+
+// printHealth writes the `verbose doctor` report: each source's session count,
+// then the problems it had reading the source and each of its files. It
+// reports whether every source read cleanly.
+func printHealth(w io.Writer, health []session.SourceHealth) bool {
+	healthy := true
+	for _, h := range health {
+		status := "ok"
+		if !h.OK() {
+			status = fmt.Sprintf("%d files with problems", len(h.Files))
+			if len(h.Errors) > 0 {
+				status = fmt.Sprintf("%d errors, %s", len(h.Errors), status)
+			}
+			healthy = false
+		}
+		fmt.Fprintf(w, "%-9s %5d sessions  %s\n", h.Name, h.Sessions, status)
+
+		for _, err := range h.Errors {
+			fmt.Fprintf(w, "  error: %s\n", err)
+		}
+		for _, f := range h.Files {
+			fmt.Fprintf(w, "  %s\n", f.Path)
+			for _, d := range f.Diagnostics {
+				fmt.Fprintf(w, "    %s\n", d)
+			}
+		}
+	}
+	return healthy
+}
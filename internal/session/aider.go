@@ -0,0 +1,593 @@
+package session
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	aiderHistoryFile = ".aider.chat.history.md"
+	aiderInputFile   = ".aider.input.history"
+)
+
+// aiderSource reads the chat logs Aider writes to the root of a repository,
+// looked for in the working directories of known sessions and of verbose
+// itself. Each "aider chat started" heading in the log begins a session.
+type aiderSource struct {
+	st      *Store
+	watcher *fsnotify.Watcher
+	mu      sync.Mutex // serialises parsing
+}
+
+func newAiderSource(st *Store) (Source, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &aiderSource{st: st, watcher: watcher}, nil
+}
+
+func (a *aiderSource) Name() string { return "aider" }
+
+// Scan reads the chat logs next to known sessions.
+func (a *aiderSource) Scan() error {
+	candidates := make(map[string]bool)
+
+	// Check CWD of existing sessions for co-located chat logs
+	a.st.mu.RLock()
+	for _, info := range a.st.infos {
+		if info.CWD != "" {
+			candidates[filepath.Join(info.CWD, aiderHistoryFile)] = true
+		}
+	}
+	a.st.mu.RUnlock()
+
+	// Check the current working directory
+	if cwd, err := os.Getwd(); err == nil {
+		candidates[filepath.Join(cwd, aiderHistoryFile)] = true
+	}
+
+	for path := range candidates {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		_ = a.watcher.Add(filepath.Dir(path))
+
+		if infos, ok := a.st.cached(path, fi); ok {
+			a.st.addIndexed(infos)
+			continue
+		}
+		a.parseFile(path)
+	}
+	return nil
+}
+
+// parseFile re-reads a chat log and records the metadata of its sessions.
+// Sessions no longer in the log are dropped.
+func (a *aiderSource) parseFile(path string) []SessionChange {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	sessions, err := ParseAiderHistory(path)
+	if err != nil {
+		a.st.noteFile(a.Name(), path, nil, err)
+		return nil
+	}
+	a.st.noteFile(a.Name(), path, nil, nil)
+	a.st.index.put(path, fi, sessionInfos(sessions))
+
+	ids := make(map[string]bool, len(sessions))
+	for _, sess := range sessions {
+		ids[sess.Info.ID] = true
+	}
+	changes := a.st.removeSessions(func(info SessionInfo) bool {
+		return info.Source == a.Name() && info.FilePath == path && !ids[info.ID]
+	})
+	return append(changes, a.st.storeMetadata(sessions)...)
+}
+
+// Watch re-reads chat logs as Aider appends to them.
+func (a *aiderSource) Watch() {
+	isHistory := func(name string) bool { return name == aiderHistoryFile }
+	ignoreDir := func(string) []SessionChange { return nil }
+	go a.st.watchFiles(a.watcher, isHistory, a.parseFile, ignoreDir, a.removePath)
+}
+
+func (a *aiderSource) removePath(path string) []SessionChange {
+	return a.st.removeUnder(a.Name(), path)
+}
+
+func (a *aiderSource) Load(info SessionInfo) (*Session, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sessions, err := ParseAiderHistory(info.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions {
+		if sess.Info.ID == info.ID {
+			a.st.publish(a.st.storeSession(sess)...)
+			return sess, nil
+		}
+	}
+	return &Session{Info: info}, nil
+}
+
+// Resume restarts Aider in the repository with the chat history loaded.
+func (a *aiderSource) Resume(info SessionInfo) (string, []string) {
+	return "aider", []string{"--restore-chat-history"}
+}
+
+func (a *aiderSource) Close() error {
+	return a.watcher.Close()
+}
+
+var (
+	aiderStarted = regexp.MustCompile(`^# aider chat started at (\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})`)
+	aiderSearch  = regexp.MustCompile(`^<{5,9} SEARCH\s*$`)
+	aiderDivider = regexp.MustCompile(`^={5,9}\s*$`)
+	aiderReplace = regexp.MustCompile(`^>{5,9} REPLACE\s*$`)
+	aiderCount   = regexp.MustCompile(`^([\d.]+)([kM]?) (.+)$`)
+)
+
+// aiderInput is a prompt from .aider.input.history, which records when each
+// was entered.
+type aiderInput struct {
+	time time.Time
+	text string
+}
+
+// aiderChat accumulates one session of a chat log.
+type aiderChat struct {
+	sess   *Session
+	cwd    string
+	inputs []aiderInput
+	ts     time.Time // time of the current turn
+	prompt []string
+	reply  []string
+	quotes []string // "> " lines of the current turn
+	turn   int      // index into events where the current turn's reply starts
+	edits  []int    // Edit events of the current turn awaiting a result
+	calls  int      // tool calls so far, for their IDs
+
+	// The shell command Aider is running, whose result is the output quoted
+	// after it
+	running string // its ToolID, empty if none
+	output  []string
+}
+
+// ParseAiderHistory reads an Aider chat log and returns its sessions, oldest
+// first. User prompts, assistant text and SEARCH/REPLACE edit blocks become
+// events; each edit block is an Edit call, or a Write for a block that creates
+// a file, whose result says whether Aider applied it. Shell commands Aider
+// ran are Bash calls whose result is the output quoted after them.
+func ParseAiderHistory(path string) ([]*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	cwd := filepath.Dir(path)
+	inputs := readAiderInputs(filepath.Join(cwd, aiderInputFile))
+	sum := sha256.Sum256([]byte(path))
+	fileHash := hex.EncodeToString(sum[:4])
+
+	var sessions []*Session
+	var chat *aiderChat
+	start := func(t time.Time) {
+		if chat != nil {
+			sessions = append(sessions, chat.finish())
+		}
+		if t.IsZero() {
+			t = fi.ModTime()
+		}
+		chat = &aiderChat{
+			sess: &Session{Info: SessionInfo{
+				ID:          fmt.Sprintf("aider-%s-%s", fileHash, t.Format("20060102-150405")),
+				ProjectDir:  cwd,
+				ProjectName: filepath.Base(cwd),
+				FilePath:    path,
+				StartTime:   t,
+				LastUpdate:  t,
+				CWD:         cwd,
+				Source:      "aider",
+			}},
+			cwd:    cwd,
+			inputs: inputs,
+			ts:     t,
+		}
+	}
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	var buf []byte
+	lineNo := 0
+	for {
+		buf, err = readFullLine(reader, buf)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(buf) == 0 {
+			break
+		}
+		line := strings.TrimRight(string(buf), " \r\n")
+		lineNo++
+
+		if m := aiderStarted.FindStringSubmatch(line); m != nil {
+			t, _ := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local)
+			start(t)
+			continue
+		}
+		if chat == nil {
+			start(time.Time{})
+		}
+
+		switch {
+		case line == "####" || strings.HasPrefix(line, "#### "):
+			if len(chat.prompt) == 0 {
+				chat.endTurn()
+			}
+			chat.prompt = append(chat.prompt, strings.TrimPrefix(strings.TrimPrefix(line, "####"), " "))
+			continue
+		case len(chat.prompt) > 0:
+			chat.flushPrompt()
+		}
+
+		if line == ">" || strings.HasPrefix(line, "> ") {
+			chat.flushReply()
+			chat.quote(strings.TrimSpace(strings.TrimPrefix(line, ">")))
+			continue
+		}
+		chat.reply = append(chat.reply, line)
+	}
+	if chat != nil {
+		sessions = append(sessions, chat.finish())
+	}
+
+	var nonEmpty []*Session
+	for _, sess := range sessions {
+		if len(sess.Events) > 0 {
+			nonEmpty = append(nonEmpty, sess)
+		}
+	}
+	return nonEmpty, nil
+}
+
+// readAiderInputs parses .aider.input.history: a "# <time>" line, then the
+// prompt's lines each prefixed with "+".
+func readAiderInputs(path string) []aiderInput {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var inputs []aiderInput
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "# "):
+			t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", strings.TrimPrefix(line, "# "), time.Local)
+			if err == nil {
+				inputs = append(inputs, aiderInput{time: t})
+			}
+		case strings.HasPrefix(line, "+") && len(inputs) > 0:
+			in := &inputs[len(inputs)-1]
+			if in.text != "" {
+				in.text += "\n"
+			}
+			in.text += strings.TrimPrefix(line, "+")
+		}
+	}
+	return inputs
+}
+
+// flushPrompt emits the prompt collected so far, timed from the input history
+// when the prompt is found there.
+func (c *aiderChat) flushPrompt() {
+	text := strings.TrimSpace(strings.Join(c.prompt, "\n"))
+	c.prompt = nil
+	if text == "" {
+		return
+	}
+
+	for i, in := range c.inputs {
+		if strings.TrimSpace(in.text) == text && !in.time.Before(c.sess.Info.StartTime) {
+			c.ts = in.time
+			c.inputs = c.inputs[i+1:]
+			break
+		}
+	}
+	c.add(Event{Type: EventUserPrompt, UserText: text})
+	c.turn = len(c.sess.Events)
+}
+
+// flushReply emits the assistant text collected so far, with each
+// SEARCH/REPLACE block in it as an Edit call.
+func (c *aiderChat) flushReply() {
+	lines := c.reply
+	c.reply = nil
+	if strings.TrimSpace(strings.Join(lines, "")) != "" {
+		c.endCommand()
+	}
+
+	var text []string
+	emitText := func() {
+		if t := strings.TrimSpace(strings.Join(text, "\n")); t != "" {
+			c.add(Event{Type: EventText, Text: t})
+		}
+		text = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if !aiderSearch.MatchString(strings.TrimSpace(lines[i])) {
+			text = append(text, lines[i])
+			continue
+		}
+
+		// The file name comes before the block, usually ahead of a fence
+		file := ""
+		for len(text) > 0 {
+			last := strings.TrimSpace(text[len(text)-1])
+			text = text[:len(text)-1]
+			if last == "" || strings.HasPrefix(last, "```") {
+				continue
+			}
+			file = strings.Trim(last, "`*:# ")
+			break
+		}
+
+		var search, replace []string
+		inReplace, closed := false, false
+		for i++; i < len(lines); i++ {
+			l := lines[i]
+			switch {
+			case !inReplace && aiderDivider.MatchString(l):
+				inReplace = true
+				continue
+			case inReplace && aiderReplace.MatchString(l):
+				closed = true
+			case inReplace:
+				replace = append(replace, l)
+				continue
+			default:
+				search = append(search, l)
+				continue
+			}
+			break
+		}
+		if !closed {
+			break
+		}
+		// Drop the closing fence
+		if i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "```") {
+			i++
+		}
+
+		emitText()
+		if file != "" && !filepath.IsAbs(file) {
+			file = filepath.Join(c.cwd, file)
+		}
+		c.calls++
+		c.edits = append(c.edits, len(c.sess.Events))
+		call := Event{
+			Type:     EventToolUse,
+			ToolName: "Edit",
+			ToolInput: map[string]interface{}{
+				"file_path":  file,
+				"old_string": strings.Join(search, "\n"),
+				"new_string": strings.Join(replace, "\n"),
+			},
+			ToolID: fmt.Sprintf("aider-%d", c.calls),
+		}
+		if len(search) == 0 {
+			// An empty SEARCH section creates the file
+			call.ToolName = "Write"
+			call.ToolInput = map[string]interface{}{
+				"file_path": file,
+				"content":   strings.Join(replace, "\n"),
+			}
+		}
+		c.add(call)
+	}
+	emitText()
+}
+
+// quote handles a "> " line: Aider's own output, such as the edits it
+// applied and the tokens a reply used.
+func (c *aiderChat) quote(line string) {
+	info := &c.sess.Info
+	switch {
+	case strings.HasPrefix(line, "Aider v"):
+		if v := strings.TrimPrefix(line, "Aider v"); !slices.Contains(info.Versions, v) {
+			info.Versions = append(info.Versions, v)
+		}
+	case strings.HasPrefix(line, "Model: ") || strings.HasPrefix(line, "Main model: "):
+		if info.Model == "" {
+			fields := strings.Fields(line[strings.Index(line, ": ")+2:])
+			if len(fields) > 0 {
+				info.Model = fields[0]
+			}
+		}
+	case strings.HasPrefix(line, "Tokens: "):
+		c.endCommand()
+		c.usage(line)
+	case strings.HasPrefix(line, "Running "):
+		c.endCommand()
+		c.calls++
+		c.running = fmt.Sprintf("aider-%d", c.calls)
+		c.add(Event{
+			Type:      EventToolUse,
+			ToolName:  "Bash",
+			ToolInput: map[string]interface{}{"command": strings.TrimPrefix(line, "Running ")},
+			ToolID:    c.running,
+		})
+	default:
+		if c.running != "" {
+			c.output = append(c.output, line)
+		}
+		c.quotes = append(c.quotes, line)
+	}
+}
+
+// endCommand records the output quoted after the running shell command as
+// its result.
+func (c *aiderChat) endCommand() {
+	if c.running == "" {
+		return
+	}
+	c.add(Event{
+		Type:       EventToolResult,
+		ToolOutput: strings.TrimSpace(strings.Join(c.output, "\n")),
+		ToolID:     c.running,
+	})
+	c.running, c.output = "", nil
+}
+
+// usage records a "Tokens: 2.1k sent, 1.0k cache hit, 300 received. Cost:
+// $0.01 message, $0.03 session." line.
+func (c *aiderChat) usage(line string) {
+	info := &c.sess.Info
+	tokens, cost, _ := strings.Cut(strings.TrimPrefix(line, "Tokens: "), "Cost: ")
+
+	var in, out int
+	for _, item := range strings.Split(strings.TrimSuffix(strings.TrimSpace(tokens), "."), ", ") {
+		m := aiderCount.FindStringSubmatch(strings.TrimSpace(item))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "k":
+			n *= 1_000
+		case "M":
+			n *= 1_000_000
+		}
+		switch m[3] {
+		case "sent":
+			in = int(n)
+		case "received":
+			out = int(n)
+		case "cache hit":
+			info.CacheReadTokens += int(n)
+		case "cache write":
+			info.CacheWriteTokens += int(n)
+		}
+	}
+	info.InputTokens += in
+	info.OutputTokens += out
+
+	// Aider prices replies itself; the first figure is this message's cost
+	if msg, _, ok := strings.Cut(cost, " message"); ok {
+		if usd, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(msg), "$"), 64); err == nil {
+			info.CostUSD += usd
+		}
+	}
+
+	// The usage belongs to the reply that just finished
+	for i := c.turn; i < len(c.sess.Events); i++ {
+		if e := &c.sess.Events[i]; e.Type != EventUserPrompt && e.InputTokens == 0 {
+			e.InputTokens, e.OutputTokens = in, out
+			break
+		}
+	}
+}
+
+// endTurn settles the current turn's edits: an edit succeeded if Aider said
+// it applied an edit to that file, and failed if Aider reported blocks that
+// did not match.
+func (c *aiderChat) endTurn() {
+	c.flushReply()
+	c.endCommand()
+	if len(c.edits) == 0 {
+		c.quotes = nil
+		return
+	}
+
+	var failures []string
+	for _, q := range c.quotes {
+		lower := strings.ToLower(q)
+		if strings.Contains(lower, "failed to match") || strings.Contains(lower, "did not conform") {
+			failures = append(failures, q)
+		}
+	}
+	for _, idx := range c.edits {
+		e := c.sess.Events[idx]
+		file, _ := e.ToolInput["file_path"].(string)
+		rel, _ := filepath.Rel(c.cwd, file)
+
+		result := Event{Type: EventToolResult, ToolID: e.ToolID}
+		for _, q := range c.quotes {
+			if q == "Applied edit to "+rel || q == "Applied edit to "+file {
+				result.ToolOutput = q
+			}
+		}
+		if result.ToolOutput == "" {
+			if len(failures) == 0 {
+				continue
+			}
+			result.ToolOutput = strings.Join(failures, "\n")
+			result.IsError = true
+		}
+		c.add(result)
+	}
+	c.edits = nil
+	c.quotes = nil
+}
+
+func (c *aiderChat) add(e Event) {
+	e.Timestamp = c.ts
+	c.sess.Events = append(c.sess.Events, e)
+}
+
+// finish closes the last turn and fills in the session's summary.
+func (c *aiderChat) finish() *Session {
+	if len(c.prompt) > 0 {
+		c.flushPrompt()
+	}
+	c.endTurn()
+
+	sess := c.sess
+	linkToolCalls(sess.Events)
+
+	info := &sess.Info
+	info.LastUpdate = c.ts
+	info.EventCount = len(sess.Events)
+	files := newFileStats()
+	for _, e := range sess.Events {
+		switch e.Type {
+		case EventUserPrompt:
+			info.UserPrompts++
+		case EventToolUse:
+			info.ToolCallCount++
+			files.add(e)
+		case EventToolResult:
+			if e.IsError {
+				info.Errors++
+			}
+		}
+	}
+	files.apply(info)
+	return sess
+}
@@ -0,0 +1,69 @@
+package session
+
+import "container/list"
+
+// defaultLoadedSessions is how many fully parsed sessions the store keeps in
+// memory. Everything else is held as SessionInfo only and re-parsed on demand.
+const defaultLoadedSessions = 16
+
+// sessionCache is a least-recently-used set of fully parsed sessions, keyed by
+// session ID. It is not safe for concurrent use; the Store guards it.
+type sessionCache struct {
+	capacity int
+	order    *list.List // front is most recently used; values are *Session
+	items    map[string]*list.Element
+}
+
+func newSessionCache(capacity int) *sessionCache {
+	return &sessionCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a cached session and marks it as most recently used.
+func (c *sessionCache) get(id string) (*Session, bool) {
+	el, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*Session), true
+}
+
+// contains reports whether a session is cached without touching its recency.
+func (c *sessionCache) contains(id string) bool {
+	_, ok := c.items[id]
+	return ok
+}
+
+// add inserts or replaces a session, marks it as most recently used and
+// returns any sessions evicted to stay within capacity.
+func (c *sessionCache) add(sess *Session) []*Session {
+	id := sess.Info.ID
+	if el, ok := c.items[id]; ok {
+		el.Value = sess
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.items[id] = c.order.PushFront(sess)
+
+	var evicted []*Session
+	for c.order.Len() > c.capacity {
+		el := c.order.Back()
+		old := c.order.Remove(el).(*Session)
+		delete(c.items, old.Info.ID)
+		evicted = append(evicted, old)
+	}
+	return evicted
+}
+
+// remove drops a session from the cache.
+func (c *sessionCache) remove(id string) {
+	if el, ok := c.items[id]; ok {
+		c.order.Remove(el)
+		delete(c.items, id)
+	}
+}
@@ -0,0 +1,291 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Checkpoint is the set of file backups Claude Code takes before a prompt, so
+// a rewind to that prompt can put the files back. The backups themselves live
+// in ~/.claude/file-history/<session-id>/.
+type Checkpoint struct {
+	MessageID string // UUID of the user prompt the checkpoint precedes
+	Prompt    string // that prompt's text, if it is in the transcript
+	Timestamp time.Time
+	Files     []CheckpointFile // sorted by path
+}
+
+// CheckpointFile is one file captured by a checkpoint.
+type CheckpointFile struct {
+	Path       string // absolute path of the file in the project
+	BackupPath string // the backup's contents; empty if the file did not exist yet
+	Version    int
+	BackupTime time.Time
+
+	// The transcript named a backup outside the file-history directory, so
+	// it is treated as missing
+	BackupRejected bool
+}
+
+// FileStatus compares a checkpointed file with the file on disk now.
+type FileStatus int
+
+const (
+	FileUnchanged     FileStatus = iota
+	FileModified                 // differs from the backup
+	FileMissing                  // backed up, but no longer on disk
+	FileAdded                    // did not exist at the checkpoint, exists now
+	FileBackupMissing            // the backup itself is gone
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case FileUnchanged:
+		return "unchanged"
+	case FileModified:
+		return "modified"
+	case FileMissing:
+		return "missing"
+	case FileAdded:
+		return "added since"
+	case FileBackupMissing:
+		return "backup missing"
+	}
+	return "unknown"
+}
+
+// rawCheckpoint is a checkpoint as it accumulates while parsing; paths are
+// resolved against the session's cwd once the session is built.
+type rawCheckpoint struct {
+	messageID string
+	timestamp time.Time
+	files     map[string]rawFileBackup
+}
+
+// fileHistoryDir returns where Claude Code keeps a session's file backups.
+func fileHistoryDir(sessionID string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".claude", "file-history", sessionID)
+}
+
+// addSnapshot records a file-history-snapshot entry. Later entries for the
+// same message (isSnapshotUpdate) add files to the existing checkpoint.
+func (p *sessionParser) addSnapshot(entry rawEntry, ts time.Time) {
+	snap := entry.Snapshot
+	if snap == nil {
+		return
+	}
+	id := snap.MessageID
+	if id == "" {
+		id = entry.MessageID
+	}
+	if id == "" {
+		return
+	}
+
+	i, ok := p.checkpointIdx[id]
+	if !ok {
+		if t := parseTimestamp(snap.Timestamp); !t.IsZero() {
+			ts = t
+		}
+		i = len(p.checkpoints)
+		p.checkpointIdx[id] = i
+		p.checkpoints = append(p.checkpoints, rawCheckpoint{
+			messageID: id,
+			timestamp: ts,
+			files:     make(map[string]rawFileBackup),
+		})
+	}
+	for path, backup := range snap.TrackedFileBackups {
+		p.checkpoints[i].files[path] = backup
+	}
+}
+
+// buildCheckpoints resolves the parsed checkpoints for a session.
+func buildCheckpoints(raw []rawCheckpoint, sessionID, cwd string, events []Event) []Checkpoint {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	prompts := make(map[string]string)
+	for _, e := range events {
+		if e.Type == EventUserPrompt && e.UUID != "" {
+			prompts[e.UUID] = e.UserText
+		}
+	}
+
+	dir := fileHistoryDir(sessionID)
+	checkpoints := make([]Checkpoint, 0, len(raw))
+	for _, rc := range raw {
+		cp := Checkpoint{
+			MessageID: rc.messageID,
+			Prompt:    prompts[rc.messageID],
+			Timestamp: rc.timestamp,
+		}
+		for path, backup := range rc.files {
+			if !filepath.IsAbs(path) && cwd != "" {
+				path = filepath.Join(cwd, path)
+			}
+			f := CheckpointFile{
+				Path:       path,
+				Version:    backup.Version,
+				BackupTime: parseTimestamp(backup.BackupTime),
+			}
+			if name := backup.BackupFileName; name != nil && *name != "" && dir != "" {
+				// The name comes from the transcript, so it must not lead
+				// out of the file-history directory
+				backupPath := filepath.Join(dir, *name)
+				if filepath.Base(*name) == *name && within(dir, backupPath) && backupPath != dir {
+					f.BackupPath = backupPath
+				} else {
+					f.BackupRejected = true
+				}
+			}
+			cp.Files = append(cp.Files, f)
+		}
+		sort.Slice(cp.Files, func(i, j int) bool {
+			return cp.Files[i].Path < cp.Files[j].Path
+		})
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints
+}
+
+// Comparison is a checkpointed file compared with the file on disk.
+type Comparison struct {
+	Status         FileStatus
+	Removed, Added string // the lines that differ, as backup and disk text
+}
+
+// Compare reads the backup and the file on disk and reports how they differ.
+func (f CheckpointFile) Compare() Comparison {
+	data, err := os.ReadFile(f.Path)
+	exists := err == nil
+	current := string(data)
+
+	if f.BackupRejected {
+		return Comparison{Status: FileBackupMissing}
+	}
+	if f.BackupPath == "" {
+		if exists {
+			return Comparison{Status: FileAdded, Added: strings.TrimSuffix(current, "\n")}
+		}
+		return Comparison{Status: FileUnchanged}
+	}
+
+	saved, err := os.ReadFile(f.BackupPath)
+	if err != nil {
+		return Comparison{Status: FileBackupMissing}
+	}
+	backup := string(saved)
+
+	c := Comparison{Status: FileUnchanged}
+	switch {
+	case !exists:
+		c.Status = FileMissing
+	case backup != current:
+		c.Status = FileModified
+	default:
+		return c
+	}
+	c.Removed, c.Added = changedLines(backup, current)
+	return c
+}
+
+// Outside lists the checkpoint's files that resolve, after symlinks, to a
+// path outside the session's working and project directories. Restore will
+// not write them, since a foreign or crafted transcript could name any file.
+func (cp Checkpoint) Outside(info SessionInfo) []string {
+	var roots []string
+	for _, dir := range []string{info.CWD, info.ProjectDir} {
+		if filepath.IsAbs(dir) {
+			roots = append(roots, resolvePath(dir))
+		}
+	}
+
+	var outside []string
+	for _, f := range cp.Files {
+		path := resolvePath(f.Path)
+		if !filepath.IsAbs(f.Path) || !slices.ContainsFunc(roots, func(root string) bool { return within(root, path) }) {
+			outside = append(outside, f.Path)
+		}
+	}
+	return outside
+}
+
+// resolvePath cleans path and resolves the symlinks in the part of it that
+// exists, so a link inside a directory cannot point a write outside it.
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+	var rest []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			slices.Reverse(rest)
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = append(rest, filepath.Base(dir))
+	}
+}
+
+// within reports whether path is root or inside it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Restore writes the checkpoint's backed-up contents back over the files on
+// disk. Files that did not exist at the checkpoint, and files Outside the
+// session's directories, are left alone. Every backup is read before anything
+// is written, so a missing or rejected backup aborts the restore without
+// touching the project.
+func (cp Checkpoint) Restore(info SessionInfo) (restored []string, err error) {
+	outside := cp.Outside(info)
+
+	type write struct {
+		path string
+		data []byte
+	}
+	var writes []write
+	for _, f := range cp.Files {
+		if slices.Contains(outside, f.Path) {
+			continue
+		}
+		if f.BackupRejected {
+			return nil, fmt.Errorf("backup of %s is outside the file-history directory", f.Path)
+		}
+		if f.BackupPath == "" {
+			continue
+		}
+		data, err := os.ReadFile(f.BackupPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading backup of %s: %w", f.Path, err)
+		}
+		writes = append(writes, write{f.Path, data})
+	}
+
+	for _, w := range writes {
+		mode := os.FileMode(0o644)
+		if fi, err := os.Stat(w.path); err == nil {
+			mode = fi.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+			return restored, err
+		}
+		if err := os.WriteFile(w.path, w.data, mode); err != nil {
+			return restored, err
+		}
+		restored = append(restored, w.path)
+	}
+	return restored, nil
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	elsewhere := filepath.Join(root, "elsewhere")
+	backups := filepath.Join(root, "backups")
+	for _, dir := range []string{project, elsewhere, backups} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A link inside the project that leads out of it
+	if err := os.Symlink(elsewhere, filepath.Join(project, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := filepath.Join(backups, "saved")
+	if err := os.WriteFile(backup, []byte("restored\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		outside bool
+	}{
+		{"in project", filepath.Join(project, "main.go"), false},
+		{"in new subdirectory", filepath.Join(project, "pkg", "new.go"), false},
+		{"dot-dot escape", filepath.Join(project, "..", "elsewhere", "dotdot.go"), true},
+		{"symlink escape", filepath.Join(project, "link", "linked.go"), true},
+		{"relative", "relative.go", true},
+		{"sibling with common prefix", project + "-other/file.go", true},
+	}
+
+	cp := Checkpoint{}
+	for _, tt := range tests {
+		cp.Files = append(cp.Files, CheckpointFile{Path: tt.path, BackupPath: backup})
+	}
+	info := SessionInfo{CWD: project, ProjectDir: project}
+
+	outside := cp.Outside(info)
+	restored, err := cp.Restore(info)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slices.Contains(outside, tt.path); got != tt.outside {
+				t.Errorf("Outside lists %s: %v, want %v", tt.path, got, tt.outside)
+			}
+			if got := slices.Contains(restored, tt.path); got == tt.outside {
+				t.Errorf("Restore wrote %s: %v, want %v", tt.path, got, !tt.outside)
+			}
+		})
+	}
+
+	for _, name := range []string{"dotdot.go", "linked.go"} {
+		if _, err := os.Stat(filepath.Join(elsewhere, name)); err == nil {
+			t.Errorf("%s was written outside the project", name)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(project, "pkg", "new.go")); err != nil || string(data) != "restored\n" {
+		t.Errorf("pkg/new.go = %q, %v; want the backup", data, err)
+	}
+}
+
+func TestCheckpointRestoreMissingBackup(t *testing.T) {
+	project := t.TempDir()
+	path := filepath.Join(project, "main.go")
+	if err := os.WriteFile(path, []byte("current\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := Checkpoint{Files: []CheckpointFile{
+		{Path: path, BackupPath: filepath.Join(project, "saved")},
+		{Path: filepath.Join(project, "other.go"), BackupPath: filepath.Join(project, "missing")},
+	}}
+	if err := os.WriteFile(filepath.Join(project, "saved"), []byte("restored\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cp.Restore(SessionInfo{CWD: project}); err == nil {
+		t.Fatal("Restore succeeded with a missing backup")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "current\n" {
+		t.Errorf("main.go = %q; a failed restore must not write anything", data)
+	}
+}
+
+func TestBuildCheckpointsBackupNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".claude", "file-history", "sess")
+
+	name := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		backup   *string
+		path     string // expected BackupPath
+		rejected bool
+	}{
+		{"plain name", name("abc@v1"), filepath.Join(dir, "abc@v1"), false},
+		{"no backup", nil, "", false},
+		{"parent directory", name("../../x"), "", true},
+		{"dot-dot", name(".."), "", true},
+		{"subdirectory", name("sub/x"), "", true},
+		{"absolute", name("/etc/passwd"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := []rawCheckpoint{{
+				messageID: "m1",
+				files:     map[string]rawFileBackup{"/p/a.go": {BackupFileName: tt.backup}},
+			}}
+			f := buildCheckpoints(raw, "sess", "/p", nil)[0].Files[0]
+			if f.BackupPath != tt.path || f.BackupRejected != tt.rejected {
+				t.Errorf("BackupPath %q, rejected %v; want %q, %v", f.BackupPath, f.BackupRejected, tt.path, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestCheckpointRestoreRejectedBackup(t *testing.T) {
+	project := t.TempDir()
+	path := filepath.Join(project, "main.go")
+	if err := os.WriteFile(path, []byte("current\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := Checkpoint{Files: []CheckpointFile{{Path: path, BackupRejected: true}}}
+	if got := cp.Files[0].Compare().Status; got != FileBackupMissing {
+		t.Errorf("Compare status %v, want %v", got, FileBackupMissing)
+	}
+	if _, err := cp.Restore(SessionInfo{CWD: project}); err == nil {
+		t.Error("Restore succeeded with a rejected backup")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "current\n" {
+		t.Errorf("main.go = %q; a rejected backup must not be restored", data)
+	}
+}
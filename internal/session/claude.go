@@ -0,0 +1,258 @@
+package session
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// claudeSource reads Claude Code's JSONL transcripts from
+// ~/.claude/projects/<project>/, including the subagent transcripts in
+// <session-id>/subagents/.
+type claudeSource struct {
+	st      *Store
+	baseDir string
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex                // serialises parsing and guards parsers
+	parsers map[string]*sessionParser // parser state for loaded transcripts, keyed by path
+}
+
+func newClaudeSource(st *Store) (Source, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &claudeSource{
+		st:      st,
+		baseDir: filepath.Join(homeDir, ".claude", "projects"),
+		watcher: watcher,
+		parsers: make(map[string]*sessionParser),
+	}, nil
+}
+
+func (c *claudeSource) Name() string { return "claude" }
+
+// Scan discovers all sessions in the Claude projects directory. Users who only
+// run other agents have none, so a missing directory is not an error; its
+// sessions are picked up if Claude Code creates it later.
+func (c *claudeSource) Scan() error {
+	_, err := c.scanBase()
+	if errors.Is(err, fs.ErrNotExist) {
+		c.st.awaitDir(c.baseDir, func() {
+			changes, _ := c.scanBase()
+			c.st.publish(changes...)
+		})
+		return nil
+	}
+	return err
+}
+
+// scanBase watches the base directory and reads every project in it. It
+// returns the sessions it added or updated.
+func (c *claudeSource) scanBase() ([]SessionChange, error) {
+	entries, err := os.ReadDir(c.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch the base directory so projects started later are picked up
+	_ = c.watcher.Add(c.baseDir)
+
+	var changes []SessionChange
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		changes = append(changes, c.scanTranscripts(filepath.Join(c.baseDir, entry.Name()))...)
+	}
+	return changes, nil
+}
+
+// scanTranscripts discovers the .jsonl transcripts in dir, and the subagent
+// transcripts in its <session-id>/subagents/ directories, and watches them.
+// Session directories are watched too, so a subagents/ directory created
+// later is noticed. It returns the sessions it added or updated.
+func (c *claudeSource) scanTranscripts(dir string) []SessionChange {
+	var changes []SessionChange
+	// Watch this directory for changes
+	_ = c.watcher.Add(dir)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		c.st.noteError(c.Name(), err)
+		return nil
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			if filepath.Base(dir) != "subagents" {
+				sessionDir := filepath.Join(dir, f.Name())
+				_ = c.watcher.Add(sessionDir)
+				subagents := filepath.Join(sessionDir, "subagents")
+				if _, err := os.Stat(subagents); err == nil {
+					changes = append(changes, c.scanTranscripts(subagents)...)
+				}
+			}
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), ".jsonl") {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		fi, _ := f.Info()
+		if infos, ok := c.st.cached(path, fi); ok {
+			changes = append(changes, c.st.addIndexed(infos)...)
+			continue
+		}
+
+		if _, change, err := c.parseFile(path); err == nil && change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes
+}
+
+// parseFile brings the parser for a transcript up to date, stores the fresh
+// Session snapshot and returns it, along with the change to report to
+// subscribers (nil if nothing new was read). Only lines appended since the
+// previous call are decoded.
+func (c *claudeSource) parseFile(path string) (*Session, *SessionChange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.parsers[path]
+	if !ok {
+		p = newSessionParser(path)
+		c.parsers[path] = p
+	}
+
+	changed, err := p.update()
+	if err != nil {
+		delete(c.parsers, path)
+		c.st.noteFile(c.Name(), path, nil, err)
+		return nil, nil, err
+	}
+
+	sess := p.session()
+	if len(sess.Events) == 0 {
+		c.st.index.put(path, p.file, nil)
+		c.st.noteFile(c.Name(), path, sess.Info.Diagnostics, nil)
+		delete(c.parsers, path)
+		return sess, nil, nil
+	}
+	c.st.noteFile(c.Name(), path, nil, nil)
+
+	known := c.st.knows(sess.Info.ID)
+	appended := p.takeAppended(sess)
+
+	c.st.index.put(path, p.file, []SessionInfo{sess.Info})
+	c.st.storeSession(sess) // the change, with its appended events, is worked out below
+
+	// Parser state is only kept for sessions still in the loaded cache
+	for path, p := range c.parsers {
+		if !c.st.isLoaded(p.info.ID) {
+			delete(c.parsers, path)
+		}
+	}
+
+	if !changed && known {
+		return sess, nil, nil
+	}
+	change := &SessionChange{Kind: SessionUpdated, ID: sess.Info.ID, Info: sess.Info, Appended: appended}
+	if !known {
+		change.Kind = SessionAdded
+	}
+	return sess, change, nil
+}
+
+// Load parses a transcript known only by its metadata. Lines appended since
+// the last parse are published here, since the watcher will find nothing new.
+func (c *claudeSource) Load(info SessionInfo) (*Session, error) {
+	sess, change, err := c.parseFile(info.FilePath)
+	if change != nil {
+		c.st.publish(*change)
+	}
+	return sess, err
+}
+
+// Watch parses lines appended to transcripts as they are written, picks up
+// new project, session and subagents directories, and drops transcripts that
+// are deleted or renamed away.
+func (c *claudeSource) Watch() {
+	isTranscript := func(name string) bool { return strings.HasSuffix(name, ".jsonl") }
+	go c.st.watchFiles(c.watcher, isTranscript, c.changed, c.watchDir, c.removePath)
+}
+
+// changed parses the lines appended to a transcript since it was last read.
+func (c *claudeSource) changed(path string) []SessionChange {
+	if _, change, err := c.parseFile(path); err == nil && change != nil {
+		return []SessionChange{*change}
+	}
+	return nil
+}
+
+// watchDir starts watching a directory created after Scan: a project
+// directory under the base directory, a session directory inside a project,
+// or a session's subagents directory. It returns the sessions found in it.
+func (c *claudeSource) watchDir(dir string) []SessionChange {
+	parent := filepath.Dir(dir)
+	switch {
+	case parent == c.baseDir, filepath.Base(dir) == "subagents":
+	case filepath.Dir(parent) == c.baseDir:
+		// A session directory; its subagents/ directory may already exist
+		_ = c.watcher.Add(dir)
+		subagents := filepath.Join(dir, "subagents")
+		if _, err := os.Stat(subagents); err != nil {
+			return nil
+		}
+		dir = subagents
+	default:
+		return nil
+	}
+
+	return c.scanTranscripts(dir)
+}
+
+// removePath drops the sessions read from path, or from any file beneath it
+// when a whole directory went away, and returns them.
+func (c *claudeSource) removePath(path string) []SessionChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	under := func(p string) bool {
+		return p == path || strings.HasPrefix(p, prefix)
+	}
+	for p := range c.parsers {
+		if under(p) {
+			delete(c.parsers, p)
+		}
+	}
+	return c.st.removeSessions(func(info SessionInfo) bool {
+		return c.owns(info) && under(info.FilePath)
+	})
+}
+
+// owns reports whether a session was read by this source.
+func (c *claudeSource) owns(info SessionInfo) bool {
+	return info.Source == "claude" || info.Source == ""
+}
+
+func (c *claudeSource) Resume(info SessionInfo) (string, []string) {
+	return "claude", []string{"--resume", info.ID}
+}
+
+func (c *claudeSource) Close() error {
+	return c.watcher.Close()
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useHome points the home, data, config and cache directories at a new
+// temporary directory, so a store sees no real sessions, and returns it.
+func useHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	return home
+}
+
+// waitForChange reads batches from sub until one holds a change of kind to
+// id, failing the test if none arrives in time.
+func waitForChange(t *testing.T, sub *Subscription, id string, kind ChangeKind) SessionChange {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case batch := <-sub.C:
+			for _, c := range batch {
+				if c.ID == id && c.Kind == kind {
+					return c
+				}
+			}
+		case <-timeout:
+			t.Fatalf("no %v change for %s", kind, id)
+		}
+	}
+}
+
+func TestClaudeProjectsCreatedLater(t *testing.T) {
+	home := useHome(t)
+
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Scan(); err != nil {
+		t.Fatalf("Scan without a Claude directory: %v", err)
+	}
+	sub := store.Subscribe()
+	store.Watch()
+
+	// Claude Code runs for the first time while the store is open
+	project := filepath.Join(home, ".claude", "projects", "-work-app")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "sess-1.jsonl"), []byte(promptLine("u1", "hello")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, sub, "sess-1", SessionAdded)
+
+	// The projects directory is watched from then on
+	if err := os.WriteFile(filepath.Join(project, "sess-2.jsonl"), []byte(promptLine("u2", "again")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, sub, "sess-2", SessionAdded)
+}
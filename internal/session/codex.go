@@ -0,0 +1,600 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// codexSource reads OpenAI Codex CLI rollout transcripts, which are written to
+// ~/.codex/sessions/YYYY/MM/DD/rollout-<timestamp>-<session-id>.jsonl.
+type codexSource struct {
+	st      *Store
+	baseDir string
+	watcher *fsnotify.Watcher
+	mu      sync.Mutex // serialises parsing
+}
+
+func newCodexSource(st *Store) (Source, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &codexSource{
+		st:      st,
+		baseDir: filepath.Join(homeDir, ".codex", "sessions"),
+		watcher: watcher,
+	}, nil
+}
+
+func (c *codexSource) Name() string { return "codex" }
+
+// Scan discovers every rollout under the sessions directory. Codex is
+// optional, so a missing directory is not an error; its rollouts are picked
+// up if Codex creates it later.
+func (c *codexSource) Scan() error {
+	if _, err := os.Stat(c.baseDir); err != nil {
+		c.st.awaitDir(c.baseDir, func() { c.st.publish(c.scanDir(c.baseDir)...) })
+		return nil
+	}
+	c.scanDir(c.baseDir)
+	return nil
+}
+
+// isRollout reports whether a file name is a Codex rollout transcript.
+func isRollout(name string) bool {
+	return strings.HasPrefix(name, "rollout-") && strings.HasSuffix(name, ".jsonl")
+}
+
+// scanDir watches dir and every directory beneath it and reads the rollouts
+// found there. It returns the sessions it added or updated.
+func (c *codexSource) scanDir(dir string) []SessionChange {
+	var changes []SessionChange
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			_ = c.watcher.Add(path)
+			return nil
+		}
+		if !isRollout(d.Name()) {
+			return nil
+		}
+
+		fi, _ := d.Info()
+		if infos, ok := c.st.cached(path, fi); ok {
+			changes = append(changes, c.st.addIndexed(infos)...)
+			return nil
+		}
+		changes = append(changes, c.parseFile(path)...)
+		return nil
+	})
+	return changes
+}
+
+// parseFile re-reads a rollout and records its metadata.
+func (c *codexSource) parseFile(path string) []SessionChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	sess, err := ParseCodexRollout(path)
+	if err != nil {
+		c.st.noteFile(c.Name(), path, nil, err)
+		return nil
+	}
+	if len(sess.Events) == 0 {
+		c.st.index.put(path, fi, nil)
+		c.st.noteFile(c.Name(), path, sess.Info.Diagnostics, nil)
+		return nil
+	}
+	c.st.noteFile(c.Name(), path, nil, nil)
+	c.st.index.put(path, fi, []SessionInfo{sess.Info})
+	return c.st.storeMetadata([]*Session{sess})
+}
+
+// Watch re-reads rollouts as Codex appends to them and picks up the date
+// directories it creates.
+func (c *codexSource) Watch() {
+	go c.st.watchFiles(c.watcher, isRollout, c.parseFile, c.scanDir, c.removePath)
+}
+
+// removePath drops the sessions read from path or from files beneath it.
+func (c *codexSource) removePath(path string) []SessionChange {
+	return c.st.removeUnder(c.Name(), path)
+}
+
+func (c *codexSource) Load(info SessionInfo) (*Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sess, err := ParseCodexRollout(info.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(sess.Events) > 0 {
+		c.st.publish(c.st.storeSession(sess)...)
+	}
+	return sess, nil
+}
+
+func (c *codexSource) Resume(info SessionInfo) (string, []string) {
+	return "codex", []string{"resume", info.ID}
+}
+
+func (c *codexSource) Close() error {
+	return c.watcher.Close()
+}
+
+// Codex rollout types (unexported). Each line is a {timestamp, type, payload}
+// item; rollouts from early releases write the payload objects bare.
+
+type codexLine struct {
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type codexMeta struct {
+	ID         string `json:"id"`
+	Timestamp  string `json:"timestamp"`
+	CWD        string `json:"cwd"`
+	CLIVersion string `json:"cli_version"`
+	Git        *struct {
+		Branch string `json:"branch"`
+	} `json:"git"`
+}
+
+type codexTurnContext struct {
+	CWD   string `json:"cwd"`
+	Model string `json:"model"`
+}
+
+type codexItem struct {
+	Type string `json:"type"`
+
+	// message
+	Role    string `json:"role"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+
+	// reasoning
+	Summary []struct {
+		Text string `json:"text"`
+	} `json:"summary"`
+
+	// function_call, custom_tool_call and local_shell_call
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Input     string `json:"input"`
+	CallID    string `json:"call_id"`
+	Action    *struct {
+		Command []string `json:"command"`
+	} `json:"action"`
+
+	// function_call_output and custom_tool_call_output
+	Output json.RawMessage `json:"output"`
+
+	// event_msg token_count
+	Info *struct {
+		LastTokenUsage *codexUsage `json:"last_token_usage"`
+	} `json:"info"`
+}
+
+type codexUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+}
+
+// codexExitCode finds the exit code in plain-text shell output.
+var codexExitCode = regexp.MustCompile(`^Exit code: (\d+)`)
+
+// ParseCodexRollout reads a Codex CLI rollout and returns a fully parsed
+// Session.
+func ParseCodexRollout(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := SessionInfo{
+		ID:       codexIDFromName(filepath.Base(path)),
+		FilePath: path,
+		Source:   "codex",
+	}
+	var events []Event
+	model := ""
+	unpriced := make(map[string]bool)
+
+	lineNo := 0
+	diagnose := func(kind DiagnosticKind, detail string) {
+		info.Diagnostics = addDiagnostic(info.Diagnostics, kind, lineNo, detail)
+	}
+
+	reader := bufio.NewReaderSize(f, 1024*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		lineNo++
+		if len(bytes.TrimSpace(line)) > 0 {
+			var cl codexLine
+			if jsonErr := json.Unmarshal(line, &cl); jsonErr != nil {
+				// A last line without a newline may still be mid-write
+				if err == nil {
+					diagnose(DiagMalformed, jsonErr.Error())
+				}
+				continue
+			}
+			payload := cl.Payload
+			if payload == nil {
+				// Early rollouts: a bare metadata line (no type), then bare items
+				payload = line
+				if cl.Type == "" {
+					cl.Type = "session_meta"
+				} else {
+					cl.Type = "response_item"
+				}
+			}
+			ts := parseTimestamp(cl.Timestamp)
+			if !ts.IsZero() {
+				if info.StartTime.IsZero() {
+					info.StartTime = ts
+				}
+				info.LastUpdate = ts
+			}
+
+			switch cl.Type {
+			case "session_meta":
+				var meta codexMeta
+				if jsonErr := json.Unmarshal(payload, &meta); jsonErr != nil {
+					diagnose(DiagSchema, "session_meta: "+jsonErr.Error())
+					continue
+				}
+				if meta.ID != "" {
+					info.ID = meta.ID
+				}
+				if info.CWD == "" {
+					info.CWD = meta.CWD
+				}
+				if info.StartTime.IsZero() {
+					info.StartTime = parseTimestamp(meta.Timestamp)
+				}
+				if meta.CLIVersion != "" && !slices.Contains(info.Versions, meta.CLIVersion) {
+					info.Versions = append(info.Versions, meta.CLIVersion)
+				}
+				if meta.Git != nil && meta.Git.Branch != "" && !slices.Contains(info.GitBranches, meta.Git.Branch) {
+					info.GitBranches = append(info.GitBranches, meta.Git.Branch)
+				}
+
+			case "turn_context":
+				var tc codexTurnContext
+				if json.Unmarshal(payload, &tc) != nil {
+					continue
+				}
+				if tc.Model != "" {
+					model = tc.Model
+					if info.Model == "" {
+						info.Model = model
+					}
+				}
+				if info.CWD == "" {
+					info.CWD = tc.CWD
+				}
+
+			case "response_item":
+				var item codexItem
+				if json.Unmarshal(payload, &item) != nil {
+					continue
+				}
+				events = append(events, codexEvents(item, ts)...)
+
+			case "event_msg":
+				var item codexItem
+				if json.Unmarshal(payload, &item) != nil || item.Type != "token_count" || item.Info == nil || item.Info.LastTokenUsage == nil {
+					continue
+				}
+				u := item.Info.LastTokenUsage
+				usage := &rawUsage{
+					InputTokens:          u.InputTokens - u.CachedInputTokens,
+					OutputTokens:         u.OutputTokens,
+					CacheReadInputTokens: u.CachedInputTokens,
+				}
+				info.InputTokens += usage.InputTokens
+				info.OutputTokens += usage.OutputTokens
+				info.CacheReadTokens += usage.CacheReadInputTokens
+				if price, ok := priceFor(model); ok {
+					info.CostUSD += price.cost(usage)
+				} else if model != "" {
+					unpriced[model] = true
+				}
+				// The usage belongs to the model response that just finished
+				if n := len(events); n > 0 && events[n-1].Type != EventUserPrompt && events[n-1].InputTokens == 0 {
+					events[n-1].InputTokens = usage.InputTokens + usage.CacheReadInputTokens
+					events[n-1].OutputTokens = usage.OutputTokens
+				}
+
+			case "compacted":
+				events = append(events, Event{Type: EventCompaction, Timestamp: ts})
+
+			default:
+				diagnose(DiagUnknownType, cl.Type)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	linkToolCalls(events)
+
+	// Early rollouts only timestamp their metadata
+	if !info.LastUpdate.After(info.StartTime) {
+		if fi, err := f.Stat(); err == nil && fi.ModTime().After(info.StartTime) {
+			info.LastUpdate = fi.ModTime()
+		}
+	}
+
+	info.ProjectDir = info.CWD
+	info.ProjectName = filepath.Base(info.CWD)
+	info.UnpricedModels = sortedKeys(unpriced)
+	info.EventCount = len(events)
+
+	files := newFileStats()
+	for _, e := range events {
+		switch e.Type {
+		case EventUserPrompt:
+			info.UserPrompts++
+		case EventToolUse:
+			info.ToolCallCount++
+			files.add(codexResolve(e, info.CWD))
+		case EventToolResult:
+			if e.IsError {
+				info.Errors++
+			}
+		}
+	}
+	files.apply(&info)
+
+	return &Session{Info: info, Events: events}, nil
+}
+
+// codexIDFromName takes the session ID from the end of a rollout file name,
+// for rollouts without a session_meta line.
+func codexIDFromName(name string) string {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "rollout-"), ".jsonl")
+	if len(name) > 36 {
+		return name[len(name)-36:]
+	}
+	return name
+}
+
+// codexEvents maps one response item onto events.
+func codexEvents(item codexItem, ts time.Time) []Event {
+	switch item.Type {
+	case "message":
+		var text []string
+		for _, c := range item.Content {
+			if c.Text != "" {
+				text = append(text, c.Text)
+			}
+		}
+		joined := strings.Join(text, "\n")
+		if joined == "" {
+			return nil
+		}
+		switch item.Role {
+		case "user":
+			// Codex sends the environment and AGENTS.md as user messages
+			trimmed := strings.TrimSpace(joined)
+			if strings.HasPrefix(trimmed, "<environment_context>") || strings.HasPrefix(trimmed, "<user_instructions>") ||
+				strings.HasPrefix(trimmed, "# AGENTS.md instructions") {
+				return nil
+			}
+			return []Event{{Type: EventUserPrompt, Timestamp: ts, UserText: joined}}
+		case "assistant":
+			return []Event{{Type: EventText, Timestamp: ts, Text: joined}}
+		}
+
+	case "reasoning":
+		var text []string
+		for _, s := range item.Summary {
+			if s.Text != "" {
+				text = append(text, s.Text)
+			}
+		}
+		if len(text) == 0 {
+			return nil
+		}
+		return []Event{{Type: EventThinking, Timestamp: ts, Thinking: strings.Join(text, "\n\n")}}
+
+	case "function_call":
+		input := make(map[string]interface{})
+		if item.Arguments != "" && json.Unmarshal([]byte(item.Arguments), &input) != nil {
+			input = map[string]interface{}{"arguments": item.Arguments}
+		}
+		if argv, ok := codexArgv(input["command"]); ok {
+			if patch, ok := codexPatchCommand(argv); ok {
+				return []Event{codexPatchCall(patch, input["workdir"], item.CallID, ts)}
+			}
+		}
+		codexShellCommand(input)
+		return []Event{{Type: EventToolUse, Timestamp: ts, ToolName: item.Name, ToolInput: input, ToolID: item.CallID}}
+
+	case "custom_tool_call":
+		// Freeform tools such as apply_patch take their input as plain text
+		input := map[string]interface{}{"input": item.Input}
+		return []Event{{Type: EventToolUse, Timestamp: ts, ToolName: item.Name, ToolInput: input, ToolID: item.CallID}}
+
+	case "local_shell_call":
+		input := make(map[string]interface{})
+		if item.Action != nil {
+			if patch, ok := codexPatchCommand(item.Action.Command); ok {
+				return []Event{codexPatchCall(patch, nil, item.CallID, ts)}
+			}
+			input["command"] = codexJoinCommand(item.Action.Command)
+		}
+		return []Event{{Type: EventToolUse, Timestamp: ts, ToolName: "shell", ToolInput: input, ToolID: item.CallID}}
+
+	case "function_call_output", "custom_tool_call_output", "local_shell_call_output":
+		output, isError := codexOutput(item.Output)
+		return []Event{{Type: EventToolResult, Timestamp: ts, ToolOutput: output, IsError: isError, ToolID: item.CallID}}
+	}
+	return nil
+}
+
+// codexShellCommand rewrites a shell call's argv into a single command line,
+// the form the other sources record.
+func codexShellCommand(input map[string]interface{}) {
+	if cmd, ok := input["cmd"].(string); ok && input["command"] == nil {
+		input["command"] = cmd
+	}
+	if args, ok := codexArgv(input["command"]); ok {
+		input["command"] = codexJoinCommand(args)
+	}
+}
+
+// codexArgv converts a decoded JSON argv to strings.
+func codexArgv(v interface{}) ([]string, bool) {
+	argv, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	args := make([]string, 0, len(argv))
+	for _, a := range argv {
+		if s, ok := a.(string); ok {
+			args = append(args, s)
+		}
+	}
+	return args, true
+}
+
+// codexPatchCommand returns the patch a shell command applies, for the
+// apply_patch calls Codex makes through its shell tool rather than as a tool
+// of their own: ["apply_patch", "<patch>"], or apply_patch reading a heredoc.
+func codexPatchCommand(args []string) (string, bool) {
+	if len(args) == 2 && (args[0] == "apply_patch" || args[0] == "applypatch") {
+		return args[1], true
+	}
+
+	script := codexJoinCommand(args)
+	first, body, ok := strings.Cut(script, "\n")
+	if !ok {
+		return "", false
+	}
+	cmd, delim, ok := strings.Cut(strings.TrimSpace(first), "<<")
+	if cmd = strings.TrimSpace(cmd); !ok || (cmd != "apply_patch" && cmd != "applypatch") {
+		return "", false
+	}
+	delim = strings.Trim(strings.TrimSpace(delim), `'"`)
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		if line == delim {
+			return strings.Join(lines, "\n"), true
+		}
+		lines = append(lines, line)
+	}
+	return "", false
+}
+
+// codexPatchCall records a patch applied through the shell as the apply_patch
+// call it amounts to, so its files reach the file lists and histories.
+func codexPatchCall(patch string, workdir interface{}, callID string, ts time.Time) Event {
+	input := map[string]interface{}{"input": patch}
+	if wd, ok := workdir.(string); ok && wd != "" {
+		input["workdir"] = wd
+	}
+	return Event{Type: EventToolUse, Timestamp: ts, ToolName: "apply_patch", ToolInput: input, ToolID: callID}
+}
+
+// codexJoinCommand joins an argv, unwrapping the `bash -lc "<script>"` that
+// Codex runs most commands through.
+func codexJoinCommand(args []string) string {
+	if len(args) == 3 && (args[1] == "-lc" || args[1] == "-c") {
+		switch filepath.Base(args[0]) {
+		case "bash", "sh", "zsh":
+			return args[2]
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// codexOutput decodes a tool output, which is plain text in current rollouts
+// and a JSON {"output", "metadata": {"exit_code"}} string in older ones.
+func codexOutput(raw json.RawMessage) (string, bool) {
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		var obj struct {
+			Content string `json:"content"`
+			Success *bool  `json:"success"`
+		}
+		if json.Unmarshal(raw, &obj) != nil {
+			return string(raw), false
+		}
+		return obj.Content, obj.Success != nil && !*obj.Success
+	}
+
+	var wrapped struct {
+		Output   string `json:"output"`
+		Metadata *struct {
+			ExitCode int `json:"exit_code"`
+		} `json:"metadata"`
+	}
+	if strings.HasPrefix(text, "{") && json.Unmarshal([]byte(text), &wrapped) == nil && wrapped.Metadata != nil {
+		return wrapped.Output, wrapped.Metadata.ExitCode != 0
+	}
+	if m := codexExitCode.FindStringSubmatch(text); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return text, code != 0
+	}
+	return text, false
+}
+
+// codexResolve makes an apply_patch call's relative paths absolute, against
+// the call's working directory or else the session's, so file lists match
+// those of the other sources.
+func codexResolve(e Event, cwd string) Event {
+	if wd, ok := e.ToolInput["workdir"].(string); ok && filepath.IsAbs(wd) {
+		cwd = wd
+	}
+	if cwd == "" || e.ToolName != "apply_patch" {
+		return e
+	}
+	patch, _ := e.ToolInput["input"].(string)
+	var lines []string
+	for _, line := range strings.Split(patch, "\n") {
+		for _, prefix := range []string{"*** Add File: ", "*** Update File: ", "*** Delete File: "} {
+			if rel, ok := strings.CutPrefix(line, prefix); ok && !filepath.IsAbs(rel) {
+				line = prefix + filepath.Join(cwd, rel)
+			}
+		}
+		lines = append(lines, line)
+	}
+	e.ToolInput = map[string]interface{}{"input": strings.Join(lines, "\n")}
+	return e
+}
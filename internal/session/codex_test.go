@@ -0,0 +1,136 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// codexRollout returns rollout lines, one {timestamp, type, payload} item per
+// payload, in the order given as type, payload pairs.
+func codexRollout(t *testing.T, items ...interface{}) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i+1 < len(items); i += 2 {
+		line, err := json.Marshal(map[string]interface{}{
+			"timestamp": "2025-01-01T00:00:00Z",
+			"type":      items[i],
+			"payload":   items[i+1],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func codexPrompt(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "message", "role": "user",
+		"content": []map[string]string{{"type": "input_text", "text": text}},
+	}
+}
+
+func TestCodexSessionsCreatedLater(t *testing.T) {
+	home := useHome(t)
+
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Scan(); err != nil {
+		t.Fatalf("Scan without a Codex directory: %v", err)
+	}
+	sub := store.Subscribe()
+	store.Watch()
+
+	// Codex runs for the first time while the store is open
+	dir := filepath.Join(home, ".codex", "sessions", "2025", "01", "01")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rollout := codexRollout(t,
+		"session_meta", map[string]string{"id": "codex-1", "cwd": "/work/app"},
+		"response_item", codexPrompt("hello"),
+	)
+	if err := os.WriteFile(filepath.Join(dir, "rollout-2025-01-01T00-00-00-codex-1.jsonl"), []byte(rollout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, sub, "codex-1", SessionAdded)
+}
+
+func TestCodexPatchCommand(t *testing.T) {
+	patch := "*** Begin Patch\n*** Update File: a.go\n-a\n+b\n*** End Patch"
+	tests := []struct {
+		name string
+		argv []string
+		want string
+		ok   bool
+	}{
+		{"argv", []string{"apply_patch", patch}, patch, true},
+		{"heredoc", []string{"bash", "-lc", "apply_patch <<'EOF'\n" + patch + "\nEOF\n"}, patch, true},
+		{"unquoted heredoc", []string{"bash", "-lc", "apply_patch <<EOF\n" + patch + "\nEOF"}, patch, true},
+		{"unterminated heredoc", []string{"bash", "-lc", "apply_patch <<'EOF'\n" + patch}, "", false},
+		{"other command", []string{"bash", "-lc", "cat <<EOF > a.go\nx\nEOF"}, "", false},
+		{"plain shell", []string{"ls", "-la"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := codexPatchCommand(tt.argv)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("codexPatchCommand() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseCodexRolloutShellPatch(t *testing.T) {
+	patch := "*** Begin Patch\n*** Add File: new.go\n+package main\n*** Update File: main.go\n-a\n+b\n*** End Patch"
+	args, err := json.Marshal(map[string]interface{}{
+		"command": []string{"apply_patch", patch},
+		"workdir": "/work/app/sub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollout := codexRollout(t,
+		"session_meta", map[string]string{"id": "codex-1", "cwd": "/work/app"},
+		"response_item", codexPrompt("add a file"),
+		"response_item", map[string]string{"type": "function_call", "name": "shell", "arguments": string(args), "call_id": "c1"},
+		"response_item", map[string]interface{}{"type": "function_call_output", "call_id": "c1", "output": "Done!"},
+	)
+	path := filepath.Join(t.TempDir(), "rollout-2025-01-01T00-00-00-codex-1.jsonl")
+	if err := os.WriteFile(path, []byte(rollout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := ParseCodexRollout(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var call *Event
+	for i := range sess.Events {
+		if sess.Events[i].Type == EventToolUse {
+			call = &sess.Events[i]
+		}
+	}
+	if call == nil || call.ToolName != "apply_patch" || PatchText(call.ToolInput) != patch {
+		t.Fatalf("patch call recorded as %+v", call)
+	}
+	if want := []string{"/work/app/sub/new.go"}; !slices.Equal(sess.Info.FilesCreated, want) {
+		t.Errorf("FilesCreated = %v, want %v", sess.Info.FilesCreated, want)
+	}
+	if want := []string{"/work/app/sub/main.go"}; !slices.Equal(sess.Info.FilesWritten, want) {
+		t.Errorf("FilesWritten = %v, want %v", sess.Info.FilesWritten, want)
+	}
+	if sess.Info.BashCommands != 0 {
+		t.Errorf("BashCommands = %d, want 0", sess.Info.BashCommands)
+	}
+}
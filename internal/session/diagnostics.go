@@ -0,0 +1,197 @@
+package session
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DiagnosticKind classifies a problem found while reading a session's file.
+type DiagnosticKind string
+
+const (
+	DiagMalformed   DiagnosticKind = "malformed"    // a line or record that does not decode
+	DiagUnknownType DiagnosticKind = "unknown type" // an entry or part type the parser does not handle
+	DiagSchema      DiagnosticKind = "schema"       // a known entry missing what it should carry
+	DiagUnreadable  DiagnosticKind = "unreadable"   // the file could not be read at all
+)
+
+const (
+	maxDiagnostics     = 20 // distinct problems kept per file
+	maxDiagnosticLines = 5  // line numbers kept per problem
+)
+
+// Diagnostic is one kind of problem in a file, with where it occurred.
+// Repeats of the same problem are counted rather than listed.
+type Diagnostic struct {
+	Kind   DiagnosticKind
+	Detail string // e.g. the decode error or the unknown type
+	Lines  []int  // first lines it occurred on, 1-based; empty for files that are not line-based
+	Count  int
+}
+
+func (d Diagnostic) String() string {
+	s := string(d.Kind) + ": " + d.Detail
+	if len(d.Lines) > 0 {
+		lines := make([]string, len(d.Lines))
+		for i, n := range d.Lines {
+			lines[i] = fmt.Sprint(n)
+		}
+		if len(d.Lines) == 1 {
+			s += " (line " + lines[0]
+		} else {
+			s += " (lines " + strings.Join(lines, ", ")
+		}
+		if d.Count > len(d.Lines) {
+			s += ", …"
+		}
+		s += ")"
+	}
+	if d.Count > 1 {
+		s += fmt.Sprintf(" ×%d", d.Count)
+	}
+	return s
+}
+
+// addDiagnostic records a problem found on a line (0 if none), merging it
+// into an earlier one of the same kind and detail.
+func addDiagnostic(ds []Diagnostic, kind DiagnosticKind, line int, detail string) []Diagnostic {
+	d := Diagnostic{Kind: kind, Detail: detail, Count: 1}
+	if line > 0 {
+		d.Lines = []int{line}
+	}
+	return mergeDiagnostic(ds, d)
+}
+
+func mergeDiagnostic(ds []Diagnostic, d Diagnostic) []Diagnostic {
+	for i := range ds {
+		e := &ds[i]
+		if e.Kind != d.Kind || e.Detail != d.Detail {
+			continue
+		}
+		e.Count += d.Count
+		for _, line := range d.Lines {
+			if len(e.Lines) < maxDiagnosticLines {
+				e.Lines = append(e.Lines, line)
+			}
+		}
+		return ds
+	}
+	if len(ds) >= maxDiagnostics {
+		return ds
+	}
+	d.Lines = slices.Clone(d.Lines)
+	return append(ds, d)
+}
+
+// FileHealth is the problems found in one file a source read.
+type FileHealth struct {
+	Path        string
+	Sessions    int // sessions read from the file
+	Diagnostics []Diagnostic
+}
+
+// SourceHealth is a source's part of the health report.
+type SourceHealth struct {
+	Name     string
+	Sessions int
+	Files    []FileHealth // files with problems, by path
+	Errors   []string     // problems reading the source outside any one file
+}
+
+// OK reports whether the source read without problems.
+func (h SourceHealth) OK() bool {
+	return len(h.Files) == 0 && len(h.Errors) == 0
+}
+
+// sourceFile is a file recorded by noteFile.
+type sourceFile struct {
+	source string
+	diags  []Diagnostic
+}
+
+// noteFile records the outcome of reading a file that yielded no session: the
+// error that stopped it or the problems it had. A file that yields a session
+// carries its problems in SessionInfo.Diagnostics, and noteFile with neither
+// clears what was recorded before.
+func (s *Store) noteFile(source, path string, diags []Diagnostic, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		kind := DiagUnreadable
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			kind = DiagMalformed
+		}
+		diags = addDiagnostic(nil, kind, 0, err.Error())
+	}
+	if len(diags) == 0 {
+		delete(s.unlisted, path)
+		return
+	}
+	s.unlisted[path] = sourceFile{source: source, diags: diags}
+}
+
+// noteError records a problem a source had outside any one file, such as a
+// directory it could not list or a database it could not query. Repeats are
+// recorded once.
+func (s *Store) noteError(source string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.Contains(s.errs[source], err.Error()) {
+		s.errs[source] = append(s.errs[source], err.Error())
+	}
+}
+
+// Health reports, per source, how many sessions it read and the files and
+// errors it had problems with. Sessions served from the index report the
+// problems found when they were last parsed.
+func (s *Store) Health() []SourceHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type key struct{ source, path string }
+	files := make(map[key]*FileHealth)
+	counts := make(map[string]int)
+
+	for _, info := range s.infos {
+		source := cmp.Or(info.Source, "claude")
+		counts[source]++
+		if len(info.Diagnostics) == 0 {
+			continue
+		}
+		// Sessions read from one database share its path
+		k := key{source, info.FilePath}
+		f := files[k]
+		if f == nil {
+			f = &FileHealth{Path: info.FilePath}
+			files[k] = f
+		}
+		f.Sessions++
+		for _, d := range info.Diagnostics {
+			f.Diagnostics = mergeDiagnostic(f.Diagnostics, d)
+		}
+	}
+	for path, u := range s.unlisted {
+		files[key{u.source, path}] = &FileHealth{Path: path, Diagnostics: u.diags}
+	}
+
+	health := make([]SourceHealth, len(s.sources))
+	for i, src := range s.sources {
+		h := SourceHealth{Name: src.Name(), Sessions: counts[src.Name()], Errors: slices.Clone(s.errs[src.Name()])}
+		for k, f := range files {
+			if k.source == h.Name {
+				h.Files = append(h.Files, *f)
+			}
+		}
+		slices.SortFunc(h.Files, func(a, b FileHealth) int { return cmp.Compare(a.Path, b.Path) })
+		health[i] = h
+	}
+	return health
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoctorWithoutIndex(t *testing.T) {
+	home := useHome(t)
+
+	// No agent has run yet: none of their directories exist
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Scan(); err != nil {
+		t.Fatalf("scan with no agent directories: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(home, "cache", "verbose", "index.db")); !os.IsNotExist(err) {
+		t.Errorf("an index was created without one being asked for: %v", err)
+	}
+	for _, h := range store.Health() {
+		if h.Sessions != 0 || len(h.Files) != 0 || len(h.Errors) != 0 {
+			t.Errorf("%s: %+v, want a source with nothing to report", h.Name, h)
+		}
+	}
+}
+
+func TestHealthReportsMalformedLines(t *testing.T) {
+	home := useHome(t)
+	path := filepath.Join(home, ".claude", "projects", "proj", "sess.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(promptLine("u1", "one")+"not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range store.Health() {
+		if h.Name != "claude" {
+			continue
+		}
+		if h.Sessions != 1 || len(h.Files) != 1 {
+			t.Fatalf("claude: %d sessions, %d files with problems; want 1 and 1", h.Sessions, len(h.Files))
+		}
+		if f := h.Files[0]; f.Path != path || len(f.Diagnostics) != 1 {
+			t.Errorf("file health = %+v, want one diagnostic for %s", f, path)
+		}
+	}
+}
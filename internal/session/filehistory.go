@@ -0,0 +1,294 @@
+package session
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change is one replacement made to a file, in the shape of an Edit call.
+type Change struct {
+	Old, New   string
+	ReplaceAll bool
+}
+
+// FileVersion is a file as it was after one tool call of a session.
+type FileVersion struct {
+	EventIndex int // the tool call in Session.Events
+	Timestamp  time.Time
+	ToolName   string
+	Changes    []Change // what the step changed; for known content, the differing lines
+
+	// Content is the whole file after this step. It is only set when Known:
+	// the file was created, or read in full, earlier in the session.
+	Content string
+	Known   bool
+
+	Failed bool // the tool call returned an error, so the file did not change
+}
+
+// FileHistory is every step of a session that touched one file.
+type FileHistory struct {
+	Path     string
+	Versions []FileVersion
+}
+
+// Reconstructed reports whether the file's starting content is known, so each
+// version is the whole file rather than just the edited fragments.
+func (h FileHistory) Reconstructed() bool {
+	return len(h.Versions) > 0 && h.Versions[0].Known
+}
+
+// readLine matches a line of Read output: a right-aligned line number, then
+// "→" or a tab, then the line.
+var readLine = regexp.MustCompile(`^\s*(\d+)(?:→|\t)(.*)$`)
+
+// FileHistories rebuilds how each file the session modified looked after every
+// step, from Write contents, Edit replacements, patch hunks and full Reads. Only the events
+// in timeline (indices into s.Events, see Timeline) are replayed. Files are
+// ordered by when they were first modified.
+func (s *Session) FileHistories(timeline []int) []FileHistory {
+	type state struct {
+		content string
+		known   bool
+		history *FileHistory
+	}
+	files := make(map[string]*state)
+	var order []string
+
+	for _, i := range timeline {
+		e := s.Events[i]
+		if e.Type != EventToolUse {
+			continue
+		}
+		for _, a := range ClassifyTool(e.ToolName, e.ToolInput) {
+			if a.Path == "" {
+				continue
+			}
+			f := files[a.Path]
+			if f == nil {
+				f = &state{history: &FileHistory{Path: a.Path}}
+				files[a.Path] = f
+			}
+
+			if a.Op == OpRead {
+				// A full read tells us the content, until we see it change.
+				// A truncated result is only a preview, so it tells us nothing.
+				if !f.known {
+					if pair, ok := s.Pair(i); ok && !pair.IsError && !pair.Truncated() {
+						f.content, f.known = readContent(e.ToolInput, pair.ToolOutput)
+					}
+				}
+				continue
+			}
+			if a.Op != OpModify && a.Op != OpCreate && a.Op != OpDelete {
+				continue
+			}
+
+			if len(f.history.Versions) == 0 {
+				order = append(order, a.Path)
+			}
+			v := FileVersion{EventIndex: i, Timestamp: e.Timestamp, ToolName: e.ToolName, Failed: e.IsError}
+
+			switch {
+			case e.IsError:
+				// Nothing happened to the file
+
+			case a.Op == OpDelete:
+				f.content, f.known = "", true
+
+			case a.Op == OpCreate:
+				content := inputString(e.ToolInput, "content")
+				if text := PatchText(e.ToolInput); text != "" {
+					content = ""
+					for _, c := range patchHunks(text, a.Path) {
+						content += c.New + "\n"
+					}
+				}
+				if f.known {
+					removed, added := changedLines(f.content, content)
+					v.Changes = []Change{{Old: removed, New: added}}
+				} else {
+					v.Changes = []Change{{New: content}}
+				}
+				f.content, f.known = content, true
+
+			default:
+				v.Changes = EditChanges(e.ToolInput)
+				if text := PatchText(e.ToolInput); text != "" {
+					v.Changes = patchHunks(text, a.Path)
+				}
+				if len(v.Changes) == 0 {
+					// The file changed in a way the call does not show
+					f.known = false
+				}
+				if f.known {
+					before := f.content
+					for _, c := range v.Changes {
+						if c.Old == "" || !strings.Contains(f.content, c.Old) {
+							// The file changed in a way we did not see
+							f.known = false
+							break
+						}
+						n := 1
+						if c.ReplaceAll {
+							n = -1
+						}
+						f.content = strings.Replace(f.content, c.Old, c.New, n)
+					}
+					if f.known {
+						removed, added := changedLines(before, f.content)
+						v.Changes = []Change{{Old: removed, New: added}}
+					}
+				}
+			}
+
+			v.Content, v.Known = f.content, f.known
+			if !v.Known {
+				v.Content = ""
+			}
+			f.history.Versions = append(f.history.Versions, v)
+		}
+	}
+
+	histories := make([]FileHistory, 0, len(order))
+	for _, path := range order {
+		histories = append(histories, *files[path].history)
+	}
+	return histories
+}
+
+// patchHunks returns the hunks a patch applies to path, each as a replacement
+// of its context and removed lines by its context and added lines. A file the
+// patch adds is a single hunk with only added lines. It reads the "*** Update
+// File:" envelope format used by OpenCode and Codex, and unified diffs.
+func patchHunks(text, path string) []Change {
+	var changes []Change
+	var old, added []string
+	flush := func() {
+		if len(old) > 0 || len(added) > 0 {
+			changes = append(changes, Change{Old: strings.Join(old, "\n"), New: strings.Join(added, "\n")})
+		}
+		old, added = nil, nil
+	}
+
+	envelope, inFile := false, false
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "*** Add File: "), strings.HasPrefix(line, "*** Update File: "),
+			strings.HasPrefix(line, "*** Delete File: "):
+			flush()
+			envelope = true
+			inFile = strings.TrimSpace(line[strings.Index(line, ": ")+2:]) == path
+		case strings.HasPrefix(line, "*** "):
+			// Begin, End, Move to and End of File markers
+		case !envelope && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "diff ")):
+			flush()
+			inFile = false
+		case !envelope && strings.HasPrefix(line, "+++ "):
+			inFile = diffPath(strings.TrimPrefix(line, "+++ ")) == path
+		case !inFile:
+		case strings.HasPrefix(line, "@@"):
+			flush()
+		case strings.HasPrefix(line, "\\"):
+			// \ No newline at end of file
+		case strings.HasPrefix(line, "+"):
+			added = append(added, line[1:])
+		case strings.HasPrefix(line, "-"):
+			old = append(old, line[1:])
+		default:
+			// Context, whose leading space an editor may have stripped
+			line = strings.TrimPrefix(line, " ")
+			old = append(old, line)
+			added = append(added, line)
+		}
+	}
+	flush()
+	return changes
+}
+
+// EditChanges returns the replacements of an Edit or MultiEdit call, in
+// either Claude Code (old_string) or OpenCode (oldString) spelling.
+func EditChanges(input map[string]interface{}) []Change {
+	if edits, ok := input["edits"].([]interface{}); ok {
+		var changes []Change
+		for _, item := range edits {
+			if m, ok := item.(map[string]interface{}); ok {
+				changes = append(changes, editChange(m))
+			}
+		}
+		return changes
+	}
+	if inputString(input, "old_string", "oldString") == "" && inputString(input, "new_string", "newString") == "" {
+		return nil
+	}
+	return []Change{editChange(input)}
+}
+
+func editChange(m map[string]interface{}) Change {
+	replaceAll, _ := m["replace_all"].(bool)
+	if !replaceAll {
+		replaceAll, _ = m["replaceAll"].(bool)
+	}
+	return Change{
+		Old:        inputString(m, "old_string", "oldString"),
+		New:        inputString(m, "new_string", "newString"),
+		ReplaceAll: replaceAll,
+	}
+}
+
+func inputString(input map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := input[key].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// readContent recovers a file's content from the output of a Read call. Only
+// reads of the whole file count; partial reads leave the content unknown.
+func readContent(input map[string]interface{}, output string) (string, bool) {
+	if _, ok := input["offset"]; ok {
+		return "", false
+	}
+	if _, ok := input["limit"]; ok {
+		return "", false
+	}
+
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		m := readLine.FindStringSubmatch(line)
+		if m == nil || m[1] != strconv.Itoa(len(lines)+1) {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(m[2], "\r"))
+	}
+	// Read stops at 2000 lines, so a read that long may be cut short
+	if len(lines) == 0 || len(lines) >= 2000 {
+		return "", false
+	}
+	return strings.Join(lines, "\n") + "\n", true
+}
+
+// changedLines trims the lines two versions share at the start and end,
+// leaving the region that differs.
+func changedLines(before, after string) (string, string) {
+	a := strings.SplitAfter(before, "\n")
+	b := strings.SplitAfter(after, "\n")
+
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+
+	removed := strings.Join(a[start:len(a)-end], "")
+	added := strings.Join(b[start:len(b)-end], "")
+	return strings.TrimSuffix(removed, "\n"), strings.TrimSuffix(added, "\n")
+}
@@ -0,0 +1,164 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+// toolCall returns a tool call and its result, paired with each other as
+// events at indices i and i+1.
+func toolCall(i int, name string, input map[string]interface{}, output string) []Event {
+	return []Event{
+		{Type: EventToolUse, ToolName: name, ToolID: name, ToolInput: input, PairIndex: i + 1},
+		{Type: EventToolResult, ToolID: name, ToolOutput: output, OutputSize: len(output), PairIndex: i},
+	}
+}
+
+// replay builds the file histories of a session made of calls.
+func replay(calls ...func(i int) []Event) []FileHistory {
+	sess := &Session{}
+	for _, call := range calls {
+		sess.Events = append(sess.Events, call(len(sess.Events))...)
+	}
+	timeline := make([]int, len(sess.Events))
+	for i := range timeline {
+		timeline[i] = i
+	}
+	return sess.FileHistories(timeline)
+}
+
+func call(name string, input map[string]interface{}, output string) func(i int) []Event {
+	return func(i int) []Event { return toolCall(i, name, input, output) }
+}
+
+func TestPatchHunks(t *testing.T) {
+	tests := []struct {
+		name, text, path string
+		want             []Change
+	}{
+		{
+			name: "envelope update",
+			text: "*** Begin Patch\n*** Update File: a.go\n@@ func main\n a\n-b\n+B\n c\n@@\n-x\n+y\n*** End Patch\n",
+			path: "a.go",
+			want: []Change{{Old: "a\nb\nc", New: "a\nB\nc"}, {Old: "x", New: "y"}},
+		},
+		{
+			name: "envelope add",
+			text: "*** Begin Patch\n*** Add File: new.go\n+package main\n+\n+func main() {}\n*** End Patch",
+			path: "new.go",
+			want: []Change{{New: "package main\n\nfunc main() {}"}},
+		},
+		{
+			name: "other file only",
+			text: "*** Begin Patch\n*** Update File: a.go\n-a\n+b\n*** Update File: b.go\n-c\n+d\n*** End Patch",
+			path: "b.go",
+			want: []Change{{Old: "c", New: "d"}},
+		},
+		{
+			name: "unified diff",
+			text: "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n\\ No newline at end of file\n" +
+				"--- a/z.go\n+++ b/z.go\n@@ -1 +1 @@\n-q\n+r\n",
+			path: "a.go",
+			want: []Change{{Old: "a\nb\nc", New: "a\nB\nc"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := patchHunks(tt.text, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patchHunks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileHistoriesPatch(t *testing.T) {
+	read := call("Read", map[string]interface{}{"file_path": "a.go"}, "     1→a\n     2→b\n     3→c\n")
+	update := call("apply_patch", map[string]interface{}{"input": "*** Begin Patch\n*** Update File: a.go\n a\n-b\n+B\n*** End Patch"}, "Done")
+
+	t.Run("update of a known file", func(t *testing.T) {
+		h := replay(read, update)
+		if len(h) != 1 || len(h[0].Versions) != 1 {
+			t.Fatalf("histories = %+v", h)
+		}
+		v := h[0].Versions[0]
+		if !v.Known || v.Content != "a\nB\nc\n" {
+			t.Errorf("content = %q (known %v), want %q", v.Content, v.Known, "a\nB\nc\n")
+		}
+	})
+
+	t.Run("update that does not apply", func(t *testing.T) {
+		stale := call("apply_patch", map[string]interface{}{"input": "*** Update File: a.go\n-zzz\n+y\n"}, "Done")
+		v := replay(read, stale)[0].Versions[0]
+		if v.Known {
+			t.Errorf("content %q still known after a hunk that does not apply", v.Content)
+		}
+	})
+
+	t.Run("update without hunks", func(t *testing.T) {
+		bare := call("apply_patch", map[string]interface{}{"input": "*** Update File: a.go\n"}, "Done")
+		v := replay(read, bare)[0].Versions[0]
+		if v.Known {
+			t.Errorf("content %q still known after a change it cannot see", v.Content)
+		}
+	})
+
+	t.Run("added file", func(t *testing.T) {
+		add := call("apply_patch", map[string]interface{}{"input": "*** Begin Patch\n*** Add File: new.go\n+package main\n*** End Patch"}, "Done")
+		edit := call("Edit", map[string]interface{}{"file_path": "new.go", "old_string": "main", "new_string": "lib"}, "ok")
+		versions := replay(add, edit)[0].Versions
+		if len(versions) != 2 {
+			t.Fatalf("%d versions, want 2", len(versions))
+		}
+		if v := versions[0]; !v.Known || v.Content != "package main\n" {
+			t.Errorf("added content = %q (known %v)", v.Content, v.Known)
+		}
+		if v := versions[1]; !v.Known || v.Content != "package lib\n" {
+			t.Errorf("edited content = %q (known %v)", v.Content, v.Known)
+		}
+	})
+}
+
+func TestFileHistoriesTruncatedRead(t *testing.T) {
+	input := map[string]interface{}{"file_path": "a.go"}
+	// Only the start of a large output is kept; the rest of the file is unseen
+	preview := func(i int) []Event {
+		events := toolCall(i, "Read", input, "     1→a\n")
+		events[1].OutputSize = 1 << 20
+		return events
+	}
+	full := call("Read", input, "     1→a\n     2→b\n")
+	edit := call("Edit", map[string]interface{}{"file_path": "a.go", "old_string": "a", "new_string": "A"}, "ok")
+
+	if v := replay(preview, edit)[0].Versions[0]; v.Known {
+		t.Errorf("content %q known from a truncated read", v.Content)
+	}
+	if v := replay(preview, full, edit)[0].Versions[0]; !v.Known || v.Content != "A\nb\n" {
+		t.Errorf("after a full read: content = %q (known %v), want %q", v.Content, v.Known, "A\nb\n")
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	tests := []struct {
+		name, before, after string
+		removed, added      string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", "", ""},
+		{"middle changed", "a\nb\nc\n", "a\nx\nc\n", "b", "x"},
+		{"line inserted", "a\nc\n", "a\nb\nc\n", "", "b"},
+		{"line deleted", "a\nb\nc\n", "a\nc\n", "b", ""},
+		{"from empty", "", "a\nb\n", "", "a\nb"},
+		{"to empty", "a\n", "", "a", ""},
+		{"no trailing newline", "a\nb", "a\nc", "b", "c"},
+		{"repeated lines", "a\na\na\n", "a\na\n", "a", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed, added := changedLines(tt.before, tt.after)
+			if removed != tt.removed || added != tt.added {
+				t.Errorf("changedLines() = %q, %q; want %q, %q", removed, added, tt.removed, tt.added)
+			}
+		})
+	}
+}
@@ -0,0 +1,630 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// geminiSource reads Gemini CLI conversations from
+// ~/.gemini/tmp/<project-hash>/: recorded chats in chats/session-*.json and
+// the checkpoints saved with /chat save in checkpoint-<tag>.json. The project
+// hash is the SHA-256 of the directory Gemini ran in.
+type geminiSource struct {
+	st      *Store
+	baseDir string
+	watcher *fsnotify.Watcher
+
+	mu sync.Mutex // serialises parsing and guards the fields below
+
+	// dirs maps project hashes to the directories of other agents' sessions
+	// and verbose's own working directory; hashed records the directories
+	// already in it, so each is hashed once.
+	dirs   map[string]string
+	hashed map[string]bool
+
+	// unresolved maps chats whose project is not known yet to their hash.
+	// They are not indexed, so they are placed again once it is.
+	unresolved map[string]string
+}
+
+func newGeminiSource(st *Store) (Source, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &geminiSource{
+		st:         st,
+		baseDir:    filepath.Join(homeDir, ".gemini", "tmp"),
+		watcher:    watcher,
+		dirs:       make(map[string]string),
+		hashed:     make(map[string]bool),
+		unresolved: make(map[string]string),
+	}, nil
+}
+
+func (g *geminiSource) Name() string { return "gemini" }
+
+// isGeminiChat reports whether a file name is a Gemini chat or checkpoint.
+func isGeminiChat(name string) bool {
+	return strings.HasSuffix(name, ".json") &&
+		(strings.HasPrefix(name, "session-") || strings.HasPrefix(name, "checkpoint-"))
+}
+
+// Scan reads every project's chats. Gemini CLI is optional, so a missing
+// directory is not an error; its chats are picked up if Gemini CLI creates it
+// later.
+func (g *geminiSource) Scan() error {
+	if _, err := os.Stat(g.baseDir); err != nil {
+		g.st.awaitDir(g.baseDir, func() { g.st.publish(g.scanDir(g.baseDir)...) })
+		return nil
+	}
+	g.scanDir(g.baseDir)
+	return nil
+}
+
+// scanDir watches dir and the project and chats directories beneath it, and
+// reads the chats found there. It returns the sessions it added or updated.
+func (g *geminiSource) scanDir(dir string) []SessionChange {
+	g.learnDirs()
+
+	var changes []SessionChange
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			// Only the project directories and their chats/ hold conversations;
+			// checkpoints/ holds file snapshots for /restore.
+			if path != g.baseDir && filepath.Dir(path) != g.baseDir && d.Name() != "chats" {
+				return filepath.SkipDir
+			}
+			_ = g.watcher.Add(path)
+			return nil
+		}
+		if !isGeminiChat(d.Name()) {
+			return nil
+		}
+
+		fi, _ := d.Info()
+		if infos, ok := g.st.cached(path, fi); ok {
+			changes = append(changes, g.st.addIndexed(infos)...)
+			return nil
+		}
+		changes = append(changes, g.parseFile(path)...)
+		return nil
+	})
+	return changes
+}
+
+// parseFile re-reads a chat and records its metadata.
+func (g *geminiSource) parseFile(path string) []SessionChange {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	sess, err := g.parse(path)
+	if err != nil {
+		g.st.noteFile(g.Name(), path, nil, err)
+		return nil
+	}
+	if len(sess.Events) == 0 {
+		g.st.index.put(path, fi, nil)
+		g.st.noteFile(g.Name(), path, sess.Info.Diagnostics, nil)
+		return nil
+	}
+	g.st.noteFile(g.Name(), path, nil, nil)
+	if _, ok := g.unresolved[path]; ok {
+		g.st.index.remove(path)
+	} else {
+		g.st.index.put(path, fi, []SessionInfo{sess.Info})
+	}
+	return g.st.storeMetadata([]*Session{sess})
+}
+
+// changed re-reads a chat Gemini saved, and any chat whose project has become
+// known since it was read.
+func (g *geminiSource) changed(path string) []SessionChange {
+	var changes []SessionChange
+	for _, chat := range g.learnDirs() {
+		if chat != path {
+			changes = append(changes, g.parseFile(chat)...)
+		}
+	}
+	return append(changes, g.parseFile(path)...)
+}
+
+// parse reads a chat and places it in its project.
+func (g *geminiSource) parse(path string) (*Session, error) {
+	sess, err := ParseGeminiChat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := g.projectHash(path)
+	dir := g.projectDir(hash, sess.Events)
+	if dir == "" {
+		// Unknown project: group its chats under the hash for now
+		g.unresolved[path] = hash
+		sess.Info.ProjectDir = filepath.Join(g.baseDir, hash)
+		sess.Info.ProjectName = "gemini-" + shortHash(hash)
+		return sess, nil
+	}
+	delete(g.unresolved, path)
+	sess.Info.ProjectDir = dir
+	sess.Info.ProjectName = filepath.Base(dir)
+	sess.Info.CWD = dir
+	return sess, nil
+}
+
+// projectHash returns the project hash directory a chat is stored under.
+func (g *geminiSource) projectHash(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "chats" {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Base(dir)
+}
+
+// learnDirs hashes the directories of sessions other agents added since it
+// last ran, and returns the unresolved chats whose project they name.
+func (g *geminiSource) learnDirs() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var fresh []string
+	if cwd, err := os.Getwd(); err == nil && !g.hashed[cwd] {
+		g.hashed[cwd] = true
+		fresh = append(fresh, cwd)
+	}
+	g.st.mu.RLock()
+	for _, info := range g.st.infos {
+		if info.Source == g.Name() {
+			continue
+		}
+		for _, dir := range []string{info.CWD, info.ProjectDir} {
+			if dir != "" && !g.hashed[dir] {
+				g.hashed[dir] = true
+				fresh = append(fresh, dir)
+			}
+		}
+	}
+	g.st.mu.RUnlock()
+
+	for _, dir := range fresh {
+		if hash := geminiHash(dir); g.dirs[hash] == "" {
+			g.dirs[hash] = dir
+		}
+	}
+
+	var resolved []string
+	for path, hash := range g.unresolved {
+		if g.dirs[hash] != "" {
+			resolved = append(resolved, path)
+		}
+	}
+	return resolved
+}
+
+// projectDir finds the directory whose hash Gemini stored a chat under: the
+// working directory of a session from another agent, verbose's own working
+// directory, or a parent of a path the chat's tools touched. Callers must
+// hold g.mu.
+func (g *geminiSource) projectDir(hash string, events []Event) string {
+	if dir := g.dirs[hash]; dir != "" {
+		return dir
+	}
+
+	for _, e := range events {
+		if e.Type != EventToolUse {
+			continue
+		}
+		for _, key := range []string{"absolute_path", "file_path", "directory", "path"} {
+			p, _ := e.ToolInput[key].(string)
+			if !filepath.IsAbs(p) {
+				continue
+			}
+			for dir := filepath.Clean(p); filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+				if geminiHash(dir) == hash {
+					return dir
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// geminiHash is how Gemini CLI names a project's directory under ~/.gemini/tmp.
+func geminiHash(dir string) string {
+	sum := sha256.Sum256([]byte(dir))
+	return hex.EncodeToString(sum[:])
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+// Watch re-reads chats as Gemini saves them and picks up new projects.
+func (g *geminiSource) Watch() {
+	go g.st.watchFiles(g.watcher, isGeminiChat, g.changed, g.scanDir, g.removePath)
+}
+
+func (g *geminiSource) removePath(path string) []SessionChange {
+	g.mu.Lock()
+	prefix := path + string(filepath.Separator)
+	for chat := range g.unresolved {
+		if chat == path || strings.HasPrefix(chat, prefix) {
+			delete(g.unresolved, chat)
+		}
+	}
+	g.mu.Unlock()
+
+	return g.st.removeUnder(g.Name(), path)
+}
+
+func (g *geminiSource) Load(info SessionInfo) (*Session, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sess, err := g.parse(info.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(sess.Events) > 0 {
+		g.st.publish(g.st.storeSession(sess)...)
+	}
+	return sess, nil
+}
+
+// Resume continues a recorded chat. Checkpoints can only be resumed from
+// inside Gemini with /chat resume, so they have no command.
+func (g *geminiSource) Resume(info SessionInfo) (string, []string) {
+	if strings.HasPrefix(filepath.Base(info.FilePath), "checkpoint-") {
+		return "", nil
+	}
+	return "gemini", []string{"--resume", info.ID}
+}
+
+func (g *geminiSource) Close() error {
+	return g.watcher.Close()
+}
+
+// Gemini CLI chat types (unexported).
+
+// geminiRecord is a chat recorded in chats/session-*.json.
+type geminiRecord struct {
+	SessionID   string          `json:"sessionId"`
+	StartTime   string          `json:"startTime"`
+	LastUpdated string          `json:"lastUpdated"`
+	Messages    []geminiMessage `json:"messages"`
+}
+
+type geminiMessage struct {
+	ID        string          `json:"id"`
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"` // "user", "gemini", "info", "error"
+	Content   json.RawMessage `json:"content"`
+	Model     string          `json:"model"`
+	Thoughts  []struct {
+		Subject     string `json:"subject"`
+		Description string `json:"description"`
+	} `json:"thoughts"`
+	Tokens *struct {
+		Input    int `json:"input"`
+		Output   int `json:"output"`
+		Cached   int `json:"cached"`
+		Thoughts int `json:"thoughts"`
+	} `json:"tokens"`
+	ToolCalls []struct {
+		ID            string                 `json:"id"`
+		Name          string                 `json:"name"`
+		Args          map[string]interface{} `json:"args"`
+		Status        string                 `json:"status"`
+		Timestamp     string                 `json:"timestamp"`
+		Result        []geminiPart           `json:"result"`
+		ResultDisplay json.RawMessage        `json:"resultDisplay"`
+	} `json:"toolCalls"`
+}
+
+// geminiContent is one turn of a checkpoint, in the Gemini API's shape.
+type geminiContent struct {
+	Role  string       `json:"role"` // "user" or "model"
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text         string `json:"text"`
+	Thought      bool   `json:"thought"`
+	FunctionCall *struct {
+		ID   string                 `json:"id"`
+		Name string                 `json:"name"`
+		Args map[string]interface{} `json:"args"`
+	} `json:"functionCall"`
+	FunctionResponse *struct {
+		ID       string                 `json:"id"`
+		Name     string                 `json:"name"`
+		Response map[string]interface{} `json:"response"`
+	} `json:"functionResponse"`
+}
+
+// ParseGeminiChat reads a Gemini CLI recorded chat or checkpoint and returns
+// a fully parsed Session. The project is left for the caller to fill in.
+func ParseGeminiChat(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := SessionInfo{
+		ID:         strings.TrimSuffix(filepath.Base(path), ".json"),
+		FilePath:   path,
+		Source:     "gemini",
+		StartTime:  fi.ModTime(),
+		LastUpdate: fi.ModTime(),
+	}
+	unpriced := make(map[string]bool)
+	var events []Event
+
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		// A checkpoint: the bare conversation history, without timestamps
+		var history []geminiContent
+		if err := json.Unmarshal(data, &history); err != nil {
+			return nil, err
+		}
+		info.ID = "gemini-" + shortHash(filepath.Base(filepath.Dir(path))) + "-" +
+			strings.TrimPrefix(info.ID, "checkpoint-")
+		events = geminiHistoryEvents(history, fi.ModTime())
+	} else {
+		var rec geminiRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, err
+		}
+		if rec.SessionID != "" {
+			info.ID = rec.SessionID
+		}
+		if t := parseTimestamp(rec.StartTime); !t.IsZero() {
+			info.StartTime = t
+		}
+		if t := parseTimestamp(rec.LastUpdated); !t.IsZero() {
+			info.LastUpdate = t
+		}
+		for _, msg := range rec.Messages {
+			switch msg.Type {
+			case "user", "gemini", "info", "warning", "error":
+			default:
+				info.Diagnostics = addDiagnostic(info.Diagnostics, DiagUnknownType, 0, "message type "+msg.Type)
+			}
+			events = append(events, geminiMessageEvents(msg)...)
+			if msg.Model != "" && info.Model == "" {
+				info.Model = msg.Model
+			}
+			if msg.Tokens == nil {
+				continue
+			}
+			usage := &rawUsage{
+				InputTokens:          msg.Tokens.Input - msg.Tokens.Cached,
+				OutputTokens:         msg.Tokens.Output + msg.Tokens.Thoughts,
+				CacheReadInputTokens: msg.Tokens.Cached,
+			}
+			info.InputTokens += usage.InputTokens
+			info.OutputTokens += usage.OutputTokens
+			info.CacheReadTokens += usage.CacheReadInputTokens
+			if price, ok := priceFor(msg.Model); ok {
+				info.CostUSD += price.cost(usage)
+			} else if msg.Model != "" {
+				unpriced[msg.Model] = true
+			}
+		}
+	}
+
+	linkToolCalls(events)
+
+	info.UnpricedModels = sortedKeys(unpriced)
+	info.EventCount = len(events)
+	files := newFileStats()
+	for _, e := range events {
+		switch e.Type {
+		case EventUserPrompt:
+			info.UserPrompts++
+		case EventToolUse:
+			info.ToolCallCount++
+			files.add(e)
+		case EventToolResult:
+			if e.IsError {
+				info.Errors++
+			}
+		}
+	}
+	files.apply(&info)
+
+	return &Session{Info: info, Events: events}, nil
+}
+
+// geminiMessageEvents maps one recorded message onto events. Tool calls carry
+// their results, so each produces a call and a result event.
+func geminiMessageEvents(msg geminiMessage) []Event {
+	ts := parseTimestamp(msg.Timestamp)
+	text := geminiText(msg.Content)
+
+	var events []Event
+	switch msg.Type {
+	case "user":
+		if text != "" {
+			events = append(events, Event{Type: EventUserPrompt, Timestamp: ts, UUID: msg.ID, UserText: text})
+		}
+		return events
+	case "gemini":
+	default:
+		return nil
+	}
+
+	for _, t := range msg.Thoughts {
+		thought := t.Description
+		if t.Subject != "" {
+			thought = "**" + t.Subject + "**\n" + thought
+		}
+		events = append(events, Event{Type: EventThinking, Timestamp: ts, Thinking: thought})
+	}
+	if text != "" {
+		events = append(events, Event{Type: EventText, Timestamp: ts, Text: text})
+	}
+	for _, call := range msg.ToolCalls {
+		callTS := parseTimestamp(call.Timestamp)
+		if callTS.IsZero() {
+			callTS = ts
+		}
+		events = append(events, Event{
+			Type:      EventToolUse,
+			Timestamp: callTS,
+			ToolName:  call.Name,
+			ToolInput: call.Args,
+			ToolID:    call.ID,
+		})
+
+		var output []string
+		for _, part := range call.Result {
+			if part.FunctionResponse != nil {
+				output = append(output, geminiResponseText(part.FunctionResponse.Response))
+			}
+		}
+		if len(output) == 0 {
+			output = append(output, geminiText(call.ResultDisplay))
+		}
+		events = append(events, Event{
+			Type:       EventToolResult,
+			Timestamp:  callTS,
+			ToolOutput: strings.Join(output, "\n"),
+			IsError:    call.Status == "error",
+			ToolID:     call.ID,
+		})
+	}
+
+	if msg.Tokens != nil && len(events) > 0 {
+		events[0].InputTokens = msg.Tokens.Input
+		events[0].OutputTokens = msg.Tokens.Output + msg.Tokens.Thoughts
+	}
+	return events
+}
+
+// geminiHistoryEvents maps a checkpoint's conversation history onto events.
+// Calls made before Gemini recorded call IDs are paired with their responses
+// by tool name, in order.
+func geminiHistoryEvents(history []geminiContent, ts time.Time) []Event {
+	var events []Event
+	pending := make(map[string][]string) // tool name → unanswered call IDs
+	n := 0
+
+	for i, turn := range history {
+		for _, part := range turn.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				id := part.FunctionCall.ID
+				if id == "" {
+					n++
+					id = fmt.Sprintf("%s-%d", part.FunctionCall.Name, n)
+				}
+				pending[part.FunctionCall.Name] = append(pending[part.FunctionCall.Name], id)
+				events = append(events, Event{
+					Type:      EventToolUse,
+					Timestamp: ts,
+					ToolName:  part.FunctionCall.Name,
+					ToolInput: part.FunctionCall.Args,
+					ToolID:    id,
+				})
+
+			case part.FunctionResponse != nil:
+				resp := part.FunctionResponse
+				id := resp.ID
+				if queue := pending[resp.Name]; len(queue) > 0 {
+					if id == "" {
+						id = queue[0]
+					}
+					pending[resp.Name] = queue[1:]
+				}
+				_, failed := resp.Response["error"]
+				events = append(events, Event{
+					Type:       EventToolResult,
+					Timestamp:  ts,
+					ToolOutput: geminiResponseText(resp.Response),
+					IsError:    failed,
+					ToolID:     id,
+				})
+
+			case part.Text == "":
+
+			case turn.Role == "user":
+				// Gemini CLI opens every chat with the workspace context
+				if i == 0 && strings.HasPrefix(part.Text, "This is the Gemini CLI.") {
+					continue
+				}
+				events = append(events, Event{Type: EventUserPrompt, Timestamp: ts, UserText: part.Text})
+
+			case part.Thought:
+				events = append(events, Event{Type: EventThinking, Timestamp: ts, Thinking: part.Text})
+
+			default:
+				if i == 1 && strings.HasPrefix(part.Text, "Got it. Thanks for the context!") {
+					continue
+				}
+				events = append(events, Event{Type: EventText, Timestamp: ts, Text: part.Text})
+			}
+		}
+	}
+	return events
+}
+
+// geminiText returns message content, which is a string or a list of parts.
+func geminiText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var parts []geminiPart
+	if json.Unmarshal(raw, &parts) == nil {
+		var text []string
+		for _, p := range parts {
+			if p.Text != "" {
+				text = append(text, p.Text)
+			}
+		}
+		return strings.Join(text, "\n")
+	}
+	return string(raw)
+}
+
+// geminiResponseText returns the output of a function response.
+func geminiResponseText(resp map[string]interface{}) string {
+	for _, key := range []string{"output", "error", "content"} {
+		if s, ok := resp[key].(string); ok {
+			return s
+		}
+	}
+	b, _ := json.Marshal(resp)
+	return string(b)
+}
@@ -0,0 +1,233 @@
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	_ "modernc.org/sqlite"
+)
+
+// indexVersion is bumped whenever the shape or meaning of the cached
+// SessionInfo changes, so stale indexes are rebuilt instead of misread.
+const indexVersion = 10
+
+// sessionIndex is an on-disk cache of SessionInfo aggregates, keyed by the
+// path, size and mtime of the file they were parsed from. Cached costs depend
+// on the price table, so the index is also rebuilt when that changes. A nil
+// index is valid and caches nothing.
+type sessionIndex struct {
+	db *sql.DB
+}
+
+// indexEntry is the cached state of a single transcript file or database.
+type indexEntry struct {
+	size  int64
+	mtime int64
+	infos []SessionInfo
+}
+
+// defaultIndexPath returns the location of the session index in the user cache dir.
+func defaultIndexPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "verbose", "index.db"), nil
+}
+
+// openIndex opens (or creates) the session index at path.
+func openIndex(path string) (*sessionIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	ix := &sessionIndex{db: db}
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		db.Close()
+		return nil, err
+	}
+	var priced string
+	if version == indexVersion {
+		db.QueryRow(`SELECT value FROM meta WHERE key = 'prices'`).Scan(&priced)
+	}
+	if version != indexVersion || priced != pricesHash() {
+		if err := ix.reset(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return ix, nil
+}
+
+// reset drops all cached data and recreates the schema.
+func (ix *sessionIndex) reset() error {
+	if ix == nil {
+		return nil
+	}
+
+	_, err := ix.db.Exec(`
+		DROP TABLE IF EXISTS sessions;
+		DROP TABLE IF EXISTS files;
+		DROP TABLE IF EXISTS meta;
+		CREATE TABLE meta (
+			key   TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE files (
+			path  TEXT PRIMARY KEY,
+			size  INTEGER NOT NULL,
+			mtime INTEGER NOT NULL
+		);
+		CREATE TABLE sessions (
+			id   TEXT PRIMARY KEY,
+			path TEXT NOT NULL,
+			info TEXT NOT NULL
+		);
+		CREATE INDEX sessions_path ON sessions(path);
+		PRAGMA user_version = ` + strconv.Itoa(indexVersion) + `;
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = ix.db.Exec(`INSERT INTO meta (key, value) VALUES ('prices', ?)`, pricesHash())
+	return err
+}
+
+// load reads the whole index into memory, keyed by file path.
+func (ix *sessionIndex) load() map[string]*indexEntry {
+	entries := make(map[string]*indexEntry)
+	if ix == nil {
+		return entries
+	}
+
+	rows, err := ix.db.Query(`SELECT path, size, mtime FROM files`)
+	if err != nil {
+		return entries
+	}
+	for rows.Next() {
+		var path string
+		e := &indexEntry{}
+		if err := rows.Scan(&path, &e.size, &e.mtime); err != nil {
+			continue
+		}
+		entries[path] = e
+	}
+	rows.Close()
+
+	rows, err = ix.db.Query(`SELECT path, info FROM sessions`)
+	if err != nil {
+		return entries
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path, data string
+		if err := rows.Scan(&path, &data); err != nil {
+			continue
+		}
+		e, ok := entries[path]
+		if !ok {
+			continue
+		}
+		var info SessionInfo
+		if err := json.Unmarshal([]byte(data), &info); err != nil {
+			// A corrupt row makes the whole file stale so it gets re-parsed.
+			e.mtime = -1
+			continue
+		}
+		e.infos = append(e.infos, info)
+	}
+
+	return entries
+}
+
+// fresh reports whether the cached entry still matches the file on disk.
+func (e *indexEntry) fresh(fi os.FileInfo) bool {
+	return e != nil && e.size == fi.Size() && e.mtime == fi.ModTime().UnixNano()
+}
+
+// put replaces the cached sessions for a file. An empty infos slice is still
+// recorded so files without events are not re-parsed on every launch.
+func (ix *sessionIndex) put(path string, fi os.FileInfo, infos []SessionInfo) {
+	if ix == nil || fi == nil {
+		return
+	}
+
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE path = ?`, path); err != nil {
+		return
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO files (path, size, mtime) VALUES (?, ?, ?)`,
+		path, fi.Size(), fi.ModTime().UnixNano()); err != nil {
+		return
+	}
+	for _, info := range infos {
+		data, err := json.Marshal(info)
+		if err != nil {
+			return
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO sessions (id, path, info) VALUES (?, ?, ?)`,
+			info.ID, path, string(data)); err != nil {
+			return
+		}
+	}
+
+	tx.Commit()
+}
+
+// retain drops every cached file that is not in keep.
+func (ix *sessionIndex) retain(keep map[string]bool) {
+	if ix == nil {
+		return
+	}
+
+	rows, err := ix.db.Query(`SELECT path FROM files`)
+	if err != nil {
+		return
+	}
+	var stale []string
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) == nil && !keep[path] {
+			stale = append(stale, path)
+		}
+	}
+	rows.Close()
+
+	for _, path := range stale {
+		ix.db.Exec(`DELETE FROM sessions WHERE path = ?`, path)
+		ix.db.Exec(`DELETE FROM files WHERE path = ?`, path)
+	}
+}
+
+// remove forgets a file that no longer exists.
+func (ix *sessionIndex) remove(path string) {
+	if ix == nil {
+		return
+	}
+	ix.db.Exec(`DELETE FROM sessions WHERE path = ?`, path)
+	ix.db.Exec(`DELETE FROM files WHERE path = ?`, path)
+}
+
+func (ix *sessionIndex) close() error {
+	if ix == nil {
+		return nil
+	}
+	return ix.db.Close()
+}
@@ -1,11 +1,20 @@
 package session
 
 import (
+	"cmp"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	_ "modernc.org/sqlite"
 )
 
@@ -13,21 +22,25 @@ import (
 
 type ocSession struct {
 	ID               string
+	ParentID         string // set on the sessions the agent tool runs subagents in
 	Title            string
 	PromptTokens     int
 	CompletionTokens int
-	Cost             float64
+	Cost             float64 // includes the cost of its child sessions
+	ChildCost        float64 // the recorded cost of its child sessions
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 }
 
 type ocMessage struct {
-	ID        string
-	SessionID string
-	Role      string // "user", "assistant"
-	Parts     string // JSON array
-	Model     string
-	CreatedAt time.Time
+	ID               string
+	SessionID        string
+	Role             string // "user", "assistant"
+	Parts            string // JSON array
+	Model            string
+	CreatedAt        time.Time
+	PromptTokens     int // only in databases whose messages record usage
+	CompletionTokens int
 }
 
 type ocPart struct {
@@ -36,53 +49,486 @@ type ocPart struct {
 }
 
 type ocTextData struct {
-	Text string `json:"text"`
+	Text     string `json:"text"`
+	Thinking string `json:"thinking"` // reasoning parts
 }
 
+// Tool parts carry either name, input (a JSON string) and tool_call_id, or in
+// early databases toolName, args and id.
+
 type ocToolCallData struct {
 	ToolName string                 `json:"toolName"`
 	Args     map[string]interface{} `json:"args"`
 	ToolID   string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Input    string                 `json:"input"`
 }
 
 type ocToolResultData struct {
-	Result  string `json:"result"`
-	IsError bool   `json:"isError"`
-	ToolID  string `json:"id"`
+	Result     string `json:"result"`
+	IsError    bool   `json:"isError"`
+	ToolID     string `json:"id"`
+	ToolCallID string `json:"tool_call_id"`
+	Content    string `json:"content"`
+	IsErrorNew bool   `json:"is_error"`
 }
 
-// ParseOpenCodeDB reads an OpenCode SQLite database and returns parsed sessions.
-func ParseOpenCodeDB(dbPath string) ([]*Session, error) {
-	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+// openCodeSource reads OpenCode sessions from both of its storage formats:
+// the SQLite databases (.opencode/opencode.db) of older versions, looked for
+// in the working directories of known sessions and of verbose itself plus any
+// added with AddDB, and the JSON file storage of newer versions.
+type openCodeSource struct {
+	st      *Store
+	storage string            // file storage directory
+	watcher *fsnotify.Watcher // watches database directories, storage project directories and active sessions' message directories
+
+	mu       sync.Mutex        // serialises database and storage reads, and guards the fields below
+	dbs      map[string]*ocDB  // tracked databases by path
+	extra    []string          // explicitly specified database paths
+	sessions map[string]string // storage session ID → session file path
+}
+
+// ocDB is what is known of a tracked database's sessions, so a change to it
+// only re-queries the sessions whose updated_at moved.
+type ocDB struct {
+	updated map[string]time.Time   // OpenCode session ID → updated_at when last read
+	infos   map[string]SessionInfo // OpenCode session ID → metadata, for sessions with events
+}
+
+func newOpenCodeSource(st *Store) (Source, error) {
+	storage, err := openCodeStorageDir()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-
-	// Query all sessions.
-	rows, err := db.Query(`SELECT id, title, prompt_tokens, completion_tokens, cost, created_at, updated_at FROM sessions`)
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &openCodeSource{
+		st:       st,
+		dbs:      make(map[string]*ocDB),
+		storage:  storage,
+		watcher:  watcher,
+		sessions: make(map[string]string),
+	}, nil
+}
 
-	var ocSessions []ocSession
+func (o *openCodeSource) Name() string { return "opencode" }
+
+// AddDB adds an explicit OpenCode database path to scan.
+func (o *openCodeSource) AddDB(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.extra = append(o.extra, path)
+}
+
+// Scan discovers and parses OpenCode databases.
+func (o *openCodeSource) Scan() error {
+	candidates := make(map[string]bool)
+
+	// Check explicitly provided paths
+	o.mu.Lock()
+	for _, p := range o.extra {
+		candidates[p] = true
+	}
+	o.mu.Unlock()
+
+	// Check CWD of existing sessions for co-located OpenCode DBs
+	o.st.mu.RLock()
+	for _, info := range o.st.infos {
+		if info.CWD != "" {
+			dbPath := filepath.Join(info.CWD, ".opencode", "opencode.db")
+			candidates[dbPath] = true
+		}
+	}
+	o.st.mu.RUnlock()
+
+	// Check the current working directory
+	if cwd, err := os.Getwd(); err == nil {
+		dbPath := filepath.Join(cwd, ".opencode", "opencode.db")
+		candidates[dbPath] = true
+	}
+
+	for dbPath := range candidates {
+		info, err := os.Stat(dbPath)
+		if err != nil {
+			continue
+		}
+
+		// The directory holds the database's -wal file too, which takes
+		// writes until they are checkpointed into the database
+		o.watch(filepath.Dir(dbPath))
+
+		wal, err := os.Stat(dbPath + "-wal")
+		if infos, ok := o.st.cached(dbPath, info); ok && (err != nil || !wal.ModTime().After(info.ModTime())) {
+			o.trackDB(dbPath, infos)
+			o.st.addIndexed(infos)
+			continue
+		}
+		o.refreshDB(dbPath)
+	}
+
+	// File storage is optional, so a missing directory is not an error
+	if _, err := os.Stat(o.storage); err == nil {
+		o.scanStorage()
+	} else {
+		o.st.awaitDir(o.storage, func() { o.st.publish(o.scanStorage()...) })
+	}
+
+	return nil
+}
+
+// scanStorage watches the file storage and reads every session in it. The
+// storage directory itself is watched for the session and message
+// directories, which OpenCode creates after it. It returns the sessions it
+// added or updated.
+func (o *openCodeSource) scanStorage() []SessionChange {
+	o.watch(o.storage)
+	o.watch(filepath.Join(o.storage, "session"))
+	o.watch(filepath.Join(o.storage, "message"))
+	return o.scanProject(filepath.Join(o.storage, "session"))
+}
+
+// scanProject reads the session files of a storage project directory, or of
+// every project directory when given the storage's session directory, and
+// watches them. It returns the sessions it added or updated.
+func (o *openCodeSource) scanProject(dir string) []SessionChange {
+	var changes []SessionChange
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			o.watch(path)
+			return nil
+		}
+		if !strings.HasPrefix(d.Name(), "ses_") || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+
+		// OpenCode rewrites the session file as messages arrive; a message
+		// directory newer than it still means messages we have not read
+		fi, _ := d.Info()
+		id := strings.TrimSuffix(d.Name(), ".json")
+		msgDir, err := os.Stat(filepath.Join(o.storage, "message", id))
+		if infos, ok := o.st.cached(path, fi); ok && (err != nil || !msgDir.ModTime().After(fi.ModTime())) {
+			o.track(id, path, fi)
+			changes = append(changes, o.st.addIndexed(infos)...)
+			return nil
+		}
+		changes = append(changes, o.parseStorage(path)...)
+		return nil
+	})
+	return changes
+}
+
+// ocActive is how recently a storage session must have been written for its
+// message directory to be watched. Watching every session's directory would
+// use up the inotify watch limit of users with many sessions; OpenCode
+// rewrites the session file when a session resumes, which starts the watch
+// again.
+const ocActive = 24 * time.Hour
+
+// track records a storage session's file and, if the session is active,
+// watches its message directory.
+func (o *openCodeSource) track(id, path string, fi os.FileInfo) {
+	o.mu.Lock()
+	o.sessions[id] = path
+	o.mu.Unlock()
+	if time.Since(fi.ModTime()) < ocActive {
+		o.watch(filepath.Join(o.storage, "message", id))
+	}
+}
+
+// watch adds a directory to the watcher. A failure, such as reaching the
+// inotify watch limit, is recorded as a source error.
+func (o *openCodeSource) watch(dir string) {
+	if err := o.watcher.Add(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		o.st.noteError(o.Name(), fmt.Errorf("watching for changes: %w", err))
+	}
+}
+
+// parseStorage re-reads a storage session and records its metadata.
+func (o *openCodeSource) parseStorage(path string) []SessionChange {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	o.track(strings.TrimSuffix(filepath.Base(path), ".json"), path, fi)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sess, err := ParseOpenCodeStorage(path)
+	if err != nil {
+		o.st.noteFile(o.Name(), path, nil, err)
+		return nil
+	}
+	if len(sess.Events) == 0 {
+		// Not listed, or no longer, if its messages were deleted
+		o.st.index.put(path, fi, nil)
+		o.st.noteFile(o.Name(), path, sess.Info.Diagnostics, nil)
+		return o.st.removeUnder(o.Name(), path)
+	}
+	o.st.noteFile(o.Name(), path, nil, nil)
+	o.st.index.put(path, fi, []SessionInfo{sess.Info})
+	return o.st.storeMetadata([]*Session{sess})
+}
+
+// storageChanged re-reads the session a changed session or message file
+// belongs to.
+func (o *openCodeSource) storageChanged(path string) []SessionChange {
+	if strings.HasPrefix(filepath.Base(path), "ses_") {
+		return o.parseStorage(path)
+	}
+	return o.sessionChanged(filepath.Base(filepath.Dir(path)))
+}
+
+// sessionChanged re-reads a tracked storage session by its ID.
+func (o *openCodeSource) sessionChanged(id string) []SessionChange {
+	o.mu.Lock()
+	path, ok := o.sessions[id]
+	o.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return o.parseStorage(path)
+}
+
+// storageDir handles a directory created in the storage: the session or
+// message directory of a new storage, a new project's session directory, or a
+// new session's message directory, whose first messages may predate the watch.
+func (o *openCodeSource) storageDir(path string) []SessionChange {
+	sessionDir := filepath.Join(o.storage, "session")
+	messageDir := filepath.Join(o.storage, "message")
+	switch {
+	case path == messageDir:
+		o.watch(path)
+	case filepath.Dir(path) == messageDir:
+		o.watch(path)
+		return o.sessionChanged(filepath.Base(path))
+	case path == sessionDir || strings.HasPrefix(path, sessionDir+string(filepath.Separator)):
+		return o.scanProject(path)
+	}
+	return nil
+}
+
+// storageRemoved drops the sessions read from a removed session file or
+// project directory.
+func (o *openCodeSource) storageRemoved(path string) []SessionChange {
+	o.mu.Lock()
+	delete(o.sessions, strings.TrimSuffix(filepath.Base(path), ".json"))
+	o.mu.Unlock()
+	return o.st.removeUnder(o.Name(), path)
+}
+
+// trackDB starts tracking a database whose sessions were read from the index.
+func (o *openCodeSource) trackDB(dbPath string, infos []SessionInfo) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	db := &ocDB{updated: make(map[string]time.Time), infos: make(map[string]SessionInfo)}
+	for _, info := range infos {
+		id := strings.TrimPrefix(info.ID, "oc-")
+		db.updated[id] = info.LastUpdate
+		db.infos[id] = info
+	}
+	o.dbs[dbPath] = db
+}
+
+// refreshDB re-reads the sessions of a database that are new or whose
+// updated_at moved since they were last read, drops the ones that are gone or
+// left without events, and returns the changes.
+func (o *openCodeSource) refreshDB(dbPath string) []SessionChange {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return nil
+	}
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+	if err != nil {
+		o.st.noteError(o.Name(), fmt.Errorf("%s: %w", dbPath, err))
+		return nil
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, updated_at FROM sessions`)
+	if err != nil {
+		o.st.noteError(o.Name(), fmt.Errorf("%s: %w", dbPath, err))
+		return nil
+	}
+	updated := make(map[string]time.Time)
 	for rows.Next() {
-		var s ocSession
-		var createdAt, updatedAt string
-		if err := rows.Scan(&s.ID, &s.Title, &s.PromptTokens, &s.CompletionTokens, &s.Cost, &createdAt, &updatedAt); err != nil {
+		var id, updatedAt string
+		if rows.Scan(&id, &updatedAt) == nil {
+			updated[id] = parseOCTime(updatedAt)
+		}
+	}
+	rows.Close()
+
+	tracked := o.dbs[dbPath]
+	if tracked == nil {
+		tracked = &ocDB{updated: make(map[string]time.Time), infos: make(map[string]SessionInfo)}
+		o.dbs[dbPath] = tracked
+	}
+
+	projectDir := filepath.Dir(filepath.Dir(dbPath)) // parent of .opencode/
+
+	var sessions []*Session
+	gone := make(map[string]bool)
+	for id, t := range updated {
+		if prev, ok := tracked.updated[id]; ok && prev.Equal(t) {
 			continue
 		}
-		s.CreatedAt = parseOCTime(createdAt)
-		s.UpdatedAt = parseOCTime(updatedAt)
-		ocSessions = append(ocSessions, s)
+		ocSessions, err := queryOCSessions(db, id)
+		if err == nil && len(ocSessions) == 0 {
+			err = sql.ErrNoRows
+		}
+		if err != nil {
+			o.st.noteError(o.Name(), fmt.Errorf("%s: session %s: %w", dbPath, id, err))
+			continue
+		}
+		sess, err := parseOCSession(db, ocSessions[0], dbPath, projectDir)
+		if err != nil {
+			o.st.noteError(o.Name(), fmt.Errorf("%s: session %s: %w", dbPath, id, err))
+			continue
+		}
+		tracked.updated[id] = t
+		if len(sess.Events) == 0 {
+			// Not listed, or no longer: its messages may have been deleted
+			gone["oc-"+id] = true
+			delete(tracked.infos, id)
+			continue
+		}
+		tracked.infos[id] = sess.Info
+		sessions = append(sessions, sess)
+	}
+
+	for id := range tracked.updated {
+		if _, ok := updated[id]; !ok {
+			gone["oc-"+id] = true
+			delete(tracked.updated, id)
+			delete(tracked.infos, id)
+		}
+	}
+
+	var changes []SessionChange
+	if len(gone) > 0 {
+		changes = o.st.removeSessions(func(info SessionInfo) bool {
+			return info.Source == o.Name() && info.FilePath == dbPath && gone[info.ID]
+		})
+	}
+	changes = append(changes, o.st.storeMetadata(sessions)...)
+
+	infos := make([]SessionInfo, 0, len(tracked.infos))
+	for _, info := range tracked.infos {
+		infos = append(infos, info)
+	}
+	o.st.index.put(dbPath, fi, infos)
+
+	return changes
+}
+
+// isOCDBFile reports whether a file name is an OpenCode database or its
+// write-ahead log.
+func isOCDBFile(name string) bool {
+	return name == "opencode.db" || name == "opencode.db-wal"
+}
+
+// Watch watches tracked databases and the file storage for new and changed
+// sessions and messages.
+func (o *openCodeSource) Watch() {
+	go o.st.watchFiles(o.watcher, func(name string) bool {
+		return isOCDBFile(name) || isOCStorageFile(name)
+	}, o.changed, o.storageDir, o.removed)
+}
+
+// changed re-reads what a changed database or storage file holds.
+func (o *openCodeSource) changed(path string) []SessionChange {
+	if isOCDBFile(filepath.Base(path)) {
+		return o.refreshDB(strings.TrimSuffix(path, "-wal"))
+	}
+	return o.storageChanged(path)
+}
+
+// removed drops the sessions of a removed database, or of a removed storage
+// session file or project directory.
+func (o *openCodeSource) removed(path string) []SessionChange {
+	switch filepath.Base(path) {
+	case "opencode.db-wal":
+		// Removed once it is checkpointed into the database
+		return nil
+	case "opencode.db":
+	default:
+		return o.storageRemoved(path)
+	}
+	o.mu.Lock()
+	delete(o.dbs, path)
+	o.mu.Unlock()
+	return o.st.removeUnder(o.Name(), path)
+}
+
+// Load reads a single session's events from its database or file storage.
+func (o *openCodeSource) Load(info SessionInfo) (*Session, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var sess *Session
+	var err error
+	if isOCStorage(info) {
+		sess, err = ParseOpenCodeStorage(info.FilePath)
+	} else {
+		sess, err = ParseOpenCodeSession(info.FilePath, strings.TrimPrefix(info.ID, "oc-"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(sess.Events) > 0 {
+		o.st.publish(o.st.storeSession(sess)...)
+	}
+	return sess, nil
+}
+
+// Resume continues a session with the flag of the OpenCode version that wrote
+// it: the SQLite versions take --resume, the file storage ones --session.
+func (o *openCodeSource) Resume(info SessionInfo) (string, []string) {
+	id := strings.TrimPrefix(info.ID, "oc-")
+	if isOCStorage(info) {
+		return "opencode", []string{"--session", id}
+	}
+	return "opencode", []string{"--resume", id}
+}
+
+func (o *openCodeSource) Close() error {
+	return o.watcher.Close()
+}
+
+// isOCStorage reports whether a session was read from the file storage rather
+// than a database.
+func isOCStorage(info SessionInfo) bool {
+	return filepath.Ext(info.FilePath) == ".json"
+}
+
+// ParseOpenCodeDB reads an OpenCode SQLite database and returns parsed sessions.
+func ParseOpenCodeDB(dbPath string) ([]*Session, error) {
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ocSessions, err := queryOCSessions(db, "")
+	if err != nil {
+		return nil, err
 	}
 
 	projectDir := filepath.Dir(filepath.Dir(dbPath)) // parent of .opencode/
 
 	var sessions []*Session
 	for _, ocs := range ocSessions {
-		sess, err := parseOCSession(db, ocs, projectDir)
+		sess, err := parseOCSession(db, ocs, dbPath, projectDir)
 		if err != nil || len(sess.Events) == 0 {
 			continue
 		}
@@ -92,21 +538,110 @@ func ParseOpenCodeDB(dbPath string) ([]*Session, error) {
 	return sessions, nil
 }
 
-func parseOCSession(db *sql.DB, ocs ocSession, projectDir string) (*Session, error) {
-	rows, err := db.Query(`SELECT id, session_id, role, parts, model, created_at FROM messages WHERE session_id = ? ORDER BY created_at ASC`, ocs.ID)
+// ParseOpenCodeSession reads a single session from an OpenCode SQLite database.
+// The ID is the OpenCode session ID, without the "oc-" prefix.
+func ParseOpenCodeSession(dbPath, id string) (*Session, error) {
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ocSessions, err := queryOCSessions(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(ocSessions) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	projectDir := filepath.Dir(filepath.Dir(dbPath)) // parent of .opencode/
+	return parseOCSession(db, ocSessions[0], dbPath, projectDir)
+}
+
+// ocColumns returns the column names of a table, or none if it does not
+// exist. Columns were added across OpenCode versions, so queries only select
+// the ones a database has.
+func ocColumns(db *sql.DB, table string) map[string]bool {
+	cols := make(map[string]bool)
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return cols
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			cols[name] = true
+		}
+	}
+	return cols
+}
+
+// ocColumn selects a column if the table has it, or a constant otherwise.
+func ocColumn(cols map[string]bool, name, fallback string) string {
+	if cols[name] {
+		return "COALESCE(" + name + ", " + fallback + ")"
+	}
+	return fallback
+}
+
+// queryOCSessions reads the sessions table, or one session of it if id is set.
+// The agent tool adds a child session's cost to its parent's, so the children's
+// recorded cost is read alongside.
+func queryOCSessions(db *sql.DB, id string) ([]ocSession, error) {
+	parentID, childCost := "''", "0"
+	if ocColumns(db, "sessions")["parent_session_id"] {
+		parentID = "COALESCE(parent_session_id, '')"
+		childCost = `(SELECT COALESCE(SUM(c.cost), 0) FROM sessions c WHERE c.parent_session_id = sessions.id AND c.id != sessions.id)`
+	}
+	query := `SELECT id, ` + parentID + `, title, prompt_tokens, completion_tokens, cost, ` + childCost + `, created_at, updated_at FROM sessions`
+	var args []interface{}
+	if id != "" {
+		query += ` WHERE id = ?`
+		args = append(args, id)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ocSessions []ocSession
+	for rows.Next() {
+		var s ocSession
+		var createdAt, updatedAt string
+		if err := rows.Scan(&s.ID, &s.ParentID, &s.Title, &s.PromptTokens, &s.CompletionTokens, &s.Cost, &s.ChildCost, &createdAt, &updatedAt); err != nil {
+			continue
+		}
+		s.CreatedAt = parseOCTime(createdAt)
+		s.UpdatedAt = parseOCTime(updatedAt)
+		ocSessions = append(ocSessions, s)
+	}
+	return ocSessions, rows.Err()
+}
+
+func parseOCSession(db *sql.DB, ocs ocSession, dbPath, projectDir string) (*Session, error) {
+	cols := ocColumns(db, "messages")
+	rows, err := db.Query(`SELECT id, session_id, role, parts, COALESCE(model, ''), created_at, `+
+		ocColumn(cols, "prompt_tokens", "0")+`, `+ocColumn(cols, "completion_tokens", "0")+
+		` FROM messages WHERE session_id = ? ORDER BY created_at ASC`, ocs.ID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var events []Event
+	var diags []Diagnostic
 	var model string
 	toolCallCount := 0
 
 	for rows.Next() {
 		var msg ocMessage
 		var createdAt string
-		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Parts, &msg.Model, &createdAt); err != nil {
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Parts, &msg.Model, &createdAt, &msg.PromptTokens, &msg.CompletionTokens); err != nil {
+			diags = addDiagnostic(diags, DiagSchema, 0, "messages row: "+err.Error())
 			continue
 		}
 		msg.CreatedAt = parseOCTime(createdAt)
@@ -114,44 +649,117 @@ func parseOCSession(db *sql.DB, ocs ocSession, projectDir string) (*Session, err
 			model = msg.Model
 		}
 
-		msgEvents, tools := parseOCMessage(msg)
+		msgEvents, tools := parseOCMessage(msg, &diags)
 		events = append(events, msgEvents...)
 		toolCallCount += tools
 	}
 
+	linkToolCalls(events)
+
 	sess := &Session{
 		Info: SessionInfo{
-			ID:           "oc-" + ocs.ID,
-			ProjectDir:   projectDir,
-			ProjectName:  filepath.Base(projectDir),
-			FilePath:     filepath.Join(projectDir, ".opencode", "opencode.db"),
-			StartTime:    ocs.CreatedAt,
-			LastUpdate:   ocs.UpdatedAt,
-			InputTokens:  ocs.PromptTokens,
-			OutputTokens: ocs.CompletionTokens,
-			CostUSD:      ocs.Cost,
-			EventCount:   len(events),
+			ID:            "oc-" + ocs.ID,
+			ProjectDir:    projectDir,
+			ProjectName:   filepath.Base(projectDir),
+			FilePath:      dbPath,
+			StartTime:     ocs.CreatedAt,
+			LastUpdate:    ocs.UpdatedAt,
+			InputTokens:   ocs.PromptTokens,
+			OutputTokens:  ocs.CompletionTokens,
+			CostUSD:       max(0, ocs.Cost-ocs.ChildCost), // its own; subagents roll up from their sessions
+			EventCount:    len(events),
 			ToolCallCount: toolCallCount,
-			Model:        model,
-			CWD:          projectDir,
-			Source:       "opencode",
+			Model:         model,
+			CWD:           projectDir,
+			Source:        "opencode",
+			Diagnostics:   diags,
 		},
 		Events: events,
 	}
+	if ocs.ParentID != "" && ocs.ParentID != ocs.ID {
+		// The agent tool runs its subagent in a child session
+		sess.Info.IsAgent = true
+		sess.Info.AgentID = sess.Info.ID
+		sess.Info.ParentID = "oc-" + ocs.ParentID
+	}
 
-	// Count user prompts.
+	// Count user prompts, errors and file operations.
+	files := newFileStats()
 	for _, e := range events {
-		if e.Type == EventUserPrompt {
+		switch e.Type {
+		case EventUserPrompt:
 			sess.Info.UserPrompts++
+		case EventToolResult:
+			if e.IsError {
+				sess.Info.Errors++
+			}
+		case EventToolUse:
+			files.add(e)
+			if e.AgentID != "" {
+				if sess.Info.AgentCalls == nil {
+					sess.Info.AgentCalls = make(map[string]string)
+				}
+				sess.Info.AgentCalls[e.AgentID] = e.ToolID
+			}
 		}
 	}
+	addOCFileVersions(db, ocs.ID, files)
+	files.apply(&sess.Info)
 
 	return sess, nil
 }
 
-func parseOCMessage(msg ocMessage) ([]Event, int) {
+// addOCFileVersions adds the files OpenCode kept versions of for a session.
+// It records a file's content as version "initial" when a tool first touches
+// it and adds a version for each change, so a file with only its initial
+// version was read, and one whose initial version is empty was created.
+func addOCFileVersions(db *sql.DB, sessionID string, files *fileStats) {
+	if len(ocColumns(db, "files")) == 0 {
+		return
+	}
+	rows, err := db.Query(`SELECT path, version, content = '' FROM files WHERE session_id = ? ORDER BY created_at ASC`, sessionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	emptyInitial := make(map[string]bool)
+	changed := make(map[string]bool)
+	var paths []string
+	for rows.Next() {
+		var path, version string
+		var empty bool
+		if rows.Scan(&path, &version, &empty) != nil {
+			continue
+		}
+		if version == "initial" {
+			if _, ok := emptyInitial[path]; !ok {
+				paths = append(paths, path)
+			}
+			emptyInitial[path] = empty
+			continue
+		}
+		changed[path] = true
+	}
+
+	for _, path := range paths {
+		switch {
+		case !changed[path]:
+			files.read[path] = true
+		case emptyInitial[path]:
+			files.created[path] = true
+		default:
+			files.modified[path] = true
+		}
+	}
+}
+
+// parseOCMessage converts a message's parts to events, recording parts it
+// cannot read in diags.
+func parseOCMessage(msg ocMessage, diags *[]Diagnostic) ([]Event, int) {
 	var parts []ocPart
 	if err := json.Unmarshal([]byte(msg.Parts), &parts); err != nil {
+		*diags = addDiagnostic(*diags, DiagMalformed, 0, "message parts: "+err.Error())
 		return nil, 0
 	}
 
@@ -181,13 +789,17 @@ func parseOCMessage(msg ocMessage) ([]Event, int) {
 
 		case "reasoning":
 			var d ocTextData
-			if json.Unmarshal(part.Data, &d) != nil || d.Text == "" {
+			if json.Unmarshal(part.Data, &d) != nil {
+				continue
+			}
+			thinking := cmp.Or(d.Thinking, d.Text)
+			if thinking == "" {
 				continue
 			}
 			events = append(events, Event{
 				Type:      EventThinking,
 				Timestamp: msg.CreatedAt,
-				Thinking:  d.Text,
+				Thinking:  thinking,
 			})
 
 		case "tool_call":
@@ -195,13 +807,23 @@ func parseOCMessage(msg ocMessage) ([]Event, int) {
 			if json.Unmarshal(part.Data, &d) != nil {
 				continue
 			}
-			events = append(events, Event{
+			input := d.Args
+			if d.Input != "" {
+				_ = json.Unmarshal([]byte(d.Input), &input)
+			}
+			e := Event{
 				Type:      EventToolUse,
 				Timestamp: msg.CreatedAt,
-				ToolName:  d.ToolName,
-				ToolInput: d.Args,
+				ToolName:  cmp.Or(d.Name, d.ToolName),
+				ToolInput: input,
 				ToolID:    d.ToolID,
-			})
+			}
+			if e.ToolName == "agent" && e.ToolID != "" {
+				// The subagent's session takes the ID of the call
+				e.AgentID = "oc-" + e.ToolID
+				e.AgentDescription, _ = input["prompt"].(string)
+			}
+			events = append(events, e)
 			toolCalls++
 
 		case "tool_result":
@@ -212,22 +834,54 @@ func parseOCMessage(msg ocMessage) ([]Event, int) {
 			events = append(events, Event{
 				Type:       EventToolResult,
 				Timestamp:  msg.CreatedAt,
-				ToolOutput: d.Result,
-				IsError:    d.IsError,
-				ToolID:     d.ToolID,
+				ToolOutput: cmp.Or(d.Content, d.Result),
+				IsError:    d.IsError || d.IsErrorNew,
+				ToolID:     cmp.Or(d.ToolCallID, d.ToolID),
 			})
 
-		case "finish":
-			// End-of-turn marker, skip.
+		case "finish", "image_url", "binary":
+			// End-of-turn marker and attachments, skip.
+
+		default:
+			*diags = addDiagnostic(*diags, DiagUnknownType, 0, "part type "+part.Type)
 		}
 	}
 
+	for i := range events {
+		events[i].UUID = msg.ID
+	}
+	attachOCTokens(events, msg.PromptTokens, msg.CompletionTokens)
+
 	return events, toolCalls
 }
 
-// parseOCTime parses a time string from the OpenCode database.
-// Tries RFC3339 first, then falls back to common SQLite formats.
+// attachOCTokens puts a message's token usage on one of its events, the
+// first text or tool call, so the detail view shows it once per message.
+func attachOCTokens(events []Event, input, output int) {
+	if len(events) == 0 {
+		return
+	}
+	at := 0
+	for i, e := range events {
+		if e.Type == EventText || e.Type == EventToolUse {
+			at = i
+			break
+		}
+	}
+	events[at].InputTokens = input
+	events[at].OutputTokens = output
+}
+
+// parseOCTime parses a time from the OpenCode database, which newer versions
+// store as Unix milliseconds and older ones as text. Tries RFC3339 first, then
+// falls back to common SQLite formats.
 func parseOCTime(s string) time.Time {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e11 {
+			return time.UnixMilli(n)
+		}
+		return time.Unix(n, 0)
+	}
 	for _, layout := range []string{
 		time.RFC3339,
 		time.RFC3339Nano,
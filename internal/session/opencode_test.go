@@ -0,0 +1,253 @@
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeOCJSON writes v as a storage file, creating its directory.
+func writeOCJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeOCMessage adds a user message with a single text part to a session.
+func writeOCMessage(t *testing.T, storage, sessionID, msgID, text string) {
+	t.Helper()
+	now := time.Now().UnixMilli()
+	writeOCJSON(t, filepath.Join(storage, "message", sessionID, msgID+".json"), map[string]interface{}{
+		"id": msgID, "sessionID": sessionID, "role": "user", "time": map[string]int64{"created": now},
+	})
+	writeOCJSON(t, filepath.Join(storage, "part", msgID, "prt_"+msgID+".json"), map[string]interface{}{
+		"id": "prt_" + msgID, "type": "text", "text": text,
+	})
+}
+
+func writeOCSession(t *testing.T, storage, sessionID, dir string) string {
+	t.Helper()
+	now := time.Now().UnixMilli()
+	path := filepath.Join(storage, "session", "proj", sessionID+".json")
+	writeOCJSON(t, path, map[string]interface{}{
+		"id": sessionID, "projectID": "proj", "directory": dir, "title": sessionID,
+		"time": map[string]int64{"created": now, "updated": now},
+	})
+	return path
+}
+
+// TestOpenCodeStorageWatch drives the OpenCode file storage through the
+// watcher while another goroutine refreshes the session list the way the UI
+// does. Run it with -race.
+func TestOpenCodeStorageWatch(t *testing.T) {
+	home := useHome(t)
+	storage := filepath.Join(home, "data", "opencode", "storage")
+	project := filepath.Join(home, "project")
+
+	writeOCSession(t, storage, "ses_1", project)
+	writeOCMessage(t, storage, "ses_1", "msg_1", "first")
+
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(store.GetSessions()); got != 1 {
+		t.Fatalf("scan found %d sessions, want 1", got)
+	}
+
+	sub := store.Subscribe()
+	store.Watch()
+
+	// Refresh concurrently with the watcher, as the UI does on every batch
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, info := range store.GetSessions() {
+				store.GetSession(info.ID)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	writeOCMessage(t, storage, "ses_1", "msg_2", "second")
+	c := waitForChange(t, sub, "oc-ses_1", SessionUpdated)
+	if c.Info.UserPrompts != 2 {
+		t.Errorf("after a new message: UserPrompts = %d, want 2", c.Info.UserPrompts)
+	}
+
+	// A new session is listed once its first message, in a message directory
+	// created after the session file, is written
+	path := writeOCSession(t, storage, "ses_2", project)
+	writeOCMessage(t, storage, "ses_2", "msg_3", "third")
+	c = waitForChange(t, sub, "oc-ses_2", SessionAdded)
+	if c.Info.UserPrompts != 1 {
+		t.Errorf("new session: UserPrompts = %d, want 1", c.Info.UserPrompts)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, sub, "oc-ses_2", SessionRemoved)
+	if got := len(store.GetSessions()); got != 1 {
+		t.Errorf("store lists %d sessions after the removal, want 1", got)
+	}
+}
+
+// createOCDB creates an OpenCode database at <project>/.opencode/opencode.db
+// with the tables the parser reads.
+func createOCDB(t *testing.T, project string) (string, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(project, ".opencode", "opencode.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`
+		CREATE TABLE sessions (
+			id TEXT PRIMARY KEY, parent_session_id TEXT, title TEXT,
+			prompt_tokens INTEGER, completion_tokens INTEGER, cost REAL,
+			created_at TEXT, updated_at TEXT
+		);
+		CREATE TABLE messages (
+			id TEXT PRIMARY KEY, session_id TEXT, role TEXT, parts TEXT,
+			model TEXT, created_at TEXT,
+			prompt_tokens INTEGER, completion_tokens INTEGER
+		);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	return path, db
+}
+
+// execOC runs a statement against a test database.
+func execOC(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ocTextParts encodes a message's parts column with a single text part.
+func ocTextParts(text string) string {
+	return fmt.Sprintf(`[{"type":"text","data":{"text":%q}}]`, text)
+}
+
+func TestOpenCodeDBSessionEmptied(t *testing.T) {
+	home := useHome(t)
+	dbPath, db := createOCDB(t, filepath.Join(home, "project"))
+	execOC(t, db, `INSERT INTO sessions VALUES ('s1', NULL, 'one', 10, 5, 0.5, '1735689600000', '1735689600000')`)
+	execOC(t, db, `INSERT INTO messages VALUES ('m1', 's1', 'user', ?, '', '1735689600000', 0, 0)`, ocTextParts("hello"))
+
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.AddOpenCodeDB(dbPath)
+	if err := store.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(store.GetSessions()); got != 1 {
+		t.Fatalf("scan found %d sessions, want 1", got)
+	}
+
+	execOC(t, db, `DELETE FROM messages WHERE session_id = 's1'`)
+	execOC(t, db, `UPDATE sessions SET updated_at = '1735689660000' WHERE id = 's1'`)
+
+	oc := store.source("opencode").(*openCodeSource)
+	changes := oc.refreshDB(dbPath)
+	if len(changes) != 1 || changes[0].ID != "oc-s1" || changes[0].Kind != SessionRemoved {
+		t.Errorf("changes = %+v, want oc-s1 removed", changes)
+	}
+	if got := len(store.GetSessions()); got != 0 {
+		t.Errorf("store lists %d sessions after the messages were deleted, want 0", got)
+	}
+}
+
+func TestOpenCodeDBCostAndTokens(t *testing.T) {
+	home := useHome(t)
+	dbPath, db := createOCDB(t, filepath.Join(home, "project"))
+	// The agent tool adds the subagent's cost to its parent's
+	execOC(t, db, `INSERT INTO sessions VALUES ('p', NULL, 'parent', 100, 20, 3.0, '1735689600000', '1735689600000')`)
+	execOC(t, db, `INSERT INTO sessions VALUES ('c', 'p', 'child', 50, 10, 1.0, '1735689600000', '1735689600000')`)
+	execOC(t, db, `INSERT INTO messages VALUES ('m1', 'p', 'user', ?, '', '1735689600000', 0, 0)`, ocTextParts("go"))
+	execOC(t, db, `INSERT INTO messages VALUES ('m2', 'p', 'assistant', ?, 'model', '1735689601000', 100, 20)`,
+		`[{"type":"reasoning","data":{"thinking":"hmm"}},{"type":"text","data":{"text":"on it"}},{"type":"tool_call","data":{"id":"t1","name":"bash","input":"{}"}}]`)
+	execOC(t, db, `INSERT INTO messages VALUES ('m3', 'c', 'user', ?, '', '1735689600000', 0, 0)`, ocTextParts("sub"))
+
+	store, err := NewStore(WithoutIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.AddOpenCodeDB(dbPath)
+	if err := store.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := make(map[string]SessionInfo)
+	for _, info := range store.GetSessions() {
+		infos[info.ID] = info
+	}
+	parent, child := infos["oc-p"], infos["oc-c"]
+	if math.Abs(parent.CostUSD-2.0) > 1e-9 {
+		t.Errorf("parent's own cost = %v, want 2", parent.CostUSD)
+	}
+	if math.Abs(child.CostUSD-1.0) > 1e-9 {
+		t.Errorf("child's cost = %v, want 1", child.CostUSD)
+	}
+	if math.Abs(parent.Inclusive.CostUSD-3.0) > 1e-9 {
+		t.Errorf("parent's inclusive cost = %v, want 3", parent.Inclusive.CostUSD)
+	}
+
+	sess := store.GetSession("oc-p")
+	if sess == nil {
+		t.Fatal("parent session did not load")
+	}
+	var withTokens []Event
+	for _, e := range sess.Events {
+		if e.InputTokens > 0 || e.OutputTokens > 0 {
+			withTokens = append(withTokens, e)
+		}
+	}
+	if len(withTokens) != 1 {
+		t.Fatalf("%d events carry the message's tokens, want 1", len(withTokens))
+	}
+	if e := withTokens[0]; e.Type != EventText || e.InputTokens != 100 || e.OutputTokens != 20 {
+		t.Errorf("tokens on %v event: %d in, %d out; want text with 100 in, 20 out", e.Type, e.InputTokens, e.OutputTokens)
+	}
+}
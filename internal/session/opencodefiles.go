@@ -0,0 +1,326 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenCode file storage types (unexported). Newer OpenCode versions keep one
+// JSON file per session, message and message part under
+// ~/.local/share/opencode/storage/:
+//
+//	session/<projectID>/<sessionID>.json
+//	message/<sessionID>/<messageID>.json
+//	part/<messageID>/<partID>.json
+//
+// IDs sort in creation order and times are Unix milliseconds.
+
+type ocsSession struct {
+	ID        string   `json:"id"`
+	ProjectID string   `json:"projectID"`
+	Directory string   `json:"directory"`
+	ParentID  string   `json:"parentID"`
+	Title     string   `json:"title"`
+	Version   string   `json:"version"`
+	Time      ocsTimes `json:"time"`
+}
+
+type ocsTimes struct {
+	Created   int64 `json:"created"`
+	Updated   int64 `json:"updated"`
+	Completed int64 `json:"completed"`
+	Start     int64 `json:"start"`
+	End       int64 `json:"end"`
+}
+
+type ocsMessage struct {
+	ID         string   `json:"id"`
+	Role       string   `json:"role"` // "user", "assistant"
+	Time       ocsTimes `json:"time"`
+	ModelID    string   `json:"modelID"`
+	ProviderID string   `json:"providerID"`
+	Cost       float64  `json:"cost"`
+	Tokens     struct {
+		Input     int `json:"input"`
+		Output    int `json:"output"`
+		Reasoning int `json:"reasoning"`
+		Cache     struct {
+			Read  int `json:"read"`
+			Write int `json:"write"`
+		} `json:"cache"`
+	} `json:"tokens"`
+	Path struct {
+		CWD string `json:"cwd"`
+	} `json:"path"`
+}
+
+type ocsPart struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"` // "text", "reasoning", "tool", "step-start", "step-finish", "file", "patch", ...
+	Text      string   `json:"text"`
+	Synthetic bool     `json:"synthetic"`
+	Time      ocsTimes `json:"time"`
+
+	// Type "tool"
+	CallID string `json:"callID"`
+	Tool   string `json:"tool"`
+	State  struct {
+		Status string                 `json:"status"` // "pending", "running", "completed", "error"
+		Input  map[string]interface{} `json:"input"`
+		Output string                 `json:"output"`
+		Error  string                 `json:"error"`
+		Time   ocsTimes               `json:"time"`
+
+		// The child session a task call runs its subagent in
+		Metadata struct {
+			SessionID string `json:"sessionId"`
+		} `json:"metadata"`
+	} `json:"state"`
+}
+
+// openCodeStorageDir returns OpenCode's storage directory, which follows the
+// XDG data directory.
+func openCodeStorageDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "opencode", "storage"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "opencode", "storage"), nil
+}
+
+// isOCStorageFile reports whether a file name is an OpenCode session or
+// message file.
+func isOCStorageFile(name string) bool {
+	return (strings.HasPrefix(name, "ses_") || strings.HasPrefix(name, "msg_")) && strings.HasSuffix(name, ".json")
+}
+
+// readOCJSON decodes a JSON file into v.
+func readOCJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readOCDir decodes every JSON file of a directory, in ID order. Files that
+// are missing or mid-write are skipped and recorded in diags.
+func readOCDir[T any](dir string, diags *[]Diagnostic) []T {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var out []T
+	for _, name := range names {
+		var v T
+		if err := readOCJSON(filepath.Join(dir, name), &v); err != nil {
+			*diags = addDiagnostic(*diags, DiagMalformed, 0, name+": "+err.Error())
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func ocsTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// ParseOpenCodeStorage reads a session from OpenCode's file storage, given
+// the path of its session file. The storage directory is the one three levels
+// above it.
+func ParseOpenCodeStorage(path string) (*Session, error) {
+	var ocs ocsSession
+	if err := readOCJSON(path, &ocs); err != nil {
+		return nil, err
+	}
+	storage := filepath.Dir(filepath.Dir(filepath.Dir(path)))
+
+	info := SessionInfo{
+		ID:          "oc-" + ocs.ID,
+		ProjectDir:  ocs.Directory,
+		ProjectName: filepath.Base(ocs.Directory),
+		FilePath:    path,
+		StartTime:   ocsTime(ocs.Time.Created),
+		LastUpdate:  ocsTime(ocs.Time.Updated),
+		CWD:         ocs.Directory,
+		Source:      "opencode",
+	}
+	if ocs.Version != "" {
+		info.Versions = []string{ocs.Version}
+	}
+	if ocs.ParentID != "" {
+		// Child sessions are the subagents the task tool starts
+		info.IsAgent = true
+		info.AgentID = info.ID
+		info.ParentID = "oc-" + ocs.ParentID
+	}
+
+	var events []Event
+	unpriced := make(map[string]bool)
+
+	for _, msg := range readOCDir[ocsMessage](filepath.Join(storage, "message", ocs.ID), &info.Diagnostics) {
+		parts := readOCDir[ocsPart](filepath.Join(storage, "part", msg.ID), &info.Diagnostics)
+		msgEvents := ocsMessageEvents(msg, parts, &info.Diagnostics)
+
+		if msg.Role == "assistant" {
+			if msg.ModelID != "" {
+				info.Model = msg.ModelID
+			}
+			usage := &rawUsage{
+				InputTokens:              msg.Tokens.Input,
+				OutputTokens:             msg.Tokens.Output + msg.Tokens.Reasoning,
+				CacheReadInputTokens:     msg.Tokens.Cache.Read,
+				CacheCreationInputTokens: msg.Tokens.Cache.Write,
+			}
+			info.InputTokens += usage.InputTokens
+			info.OutputTokens += usage.OutputTokens
+			info.CacheReadTokens += usage.CacheReadInputTokens
+			info.CacheWriteTokens += usage.CacheCreationInputTokens
+
+			// OpenCode prices messages itself; fall back to our prices
+			// for providers it reports no cost for
+			switch price, ok := priceFor(msg.ModelID); {
+			case msg.Cost > 0:
+				info.CostUSD += msg.Cost
+			case ok:
+				info.CostUSD += price.cost(usage)
+			case msg.ModelID != "" && usage.InputTokens+usage.OutputTokens > 0:
+				unpriced[msg.ModelID] = true
+			}
+
+			attachOCTokens(msgEvents, usage.InputTokens+usage.CacheReadInputTokens+usage.CacheCreationInputTokens, usage.OutputTokens)
+		}
+		if msg.Path.CWD != "" {
+			info.CWD = msg.Path.CWD
+		}
+		if t := ocsTime(max(msg.Time.Created, msg.Time.Completed)); t.After(info.LastUpdate) {
+			info.LastUpdate = t
+		}
+		events = append(events, msgEvents...)
+	}
+
+	linkToolCalls(events)
+
+	info.EventCount = len(events)
+	info.UnpricedModels = sortedKeys(unpriced)
+	files := newFileStats()
+	for _, e := range events {
+		switch e.Type {
+		case EventUserPrompt:
+			info.UserPrompts++
+		case EventToolUse:
+			info.ToolCallCount++
+			files.add(e)
+			if e.AgentID != "" {
+				if info.AgentCalls == nil {
+					info.AgentCalls = make(map[string]string)
+				}
+				info.AgentCalls[e.AgentID] = e.ToolID
+			}
+		case EventToolResult:
+			if e.IsError {
+				info.Errors++
+			}
+		}
+	}
+	files.apply(&info)
+
+	return &Session{Info: info, Events: events}, nil
+}
+
+// ocsMessageEvents converts the parts of a message to events, recording part
+// types it does not know in diags. A tool part holds both the call and, once
+// it has finished, its result.
+func ocsMessageEvents(msg ocsMessage, parts []ocsPart, diags *[]Diagnostic) []Event {
+	created := ocsTime(msg.Time.Created)
+	at := func(t ocsTimes) time.Time {
+		if t.Start != 0 {
+			return ocsTime(t.Start)
+		}
+		return created
+	}
+
+	var events []Event
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			if p.Text == "" || p.Synthetic {
+				continue
+			}
+			if msg.Role == "user" {
+				events = append(events, Event{Type: EventUserPrompt, Timestamp: created, UUID: p.ID, UserText: p.Text})
+			} else {
+				events = append(events, Event{Type: EventText, Timestamp: at(p.Time), UUID: p.ID, Text: p.Text})
+			}
+
+		case "reasoning":
+			if p.Text == "" {
+				continue
+			}
+			events = append(events, Event{Type: EventThinking, Timestamp: at(p.Time), UUID: p.ID, Thinking: p.Text})
+
+		case "tool":
+			call := Event{
+				Type:      EventToolUse,
+				Timestamp: at(p.State.Time),
+				UUID:      p.ID,
+				ToolName:  p.Tool,
+				ToolInput: p.State.Input,
+				ToolID:    p.CallID,
+			}
+			if sid := p.State.Metadata.SessionID; sid != "" {
+				call.AgentID = "oc-" + sid
+				call.AgentDescription, _ = p.State.Input["description"].(string)
+			}
+			events = append(events, call)
+
+			end := ocsTime(p.State.Time.End)
+			if end.IsZero() {
+				end = at(p.State.Time)
+			}
+			switch p.State.Status {
+			case "completed":
+				events = append(events, Event{
+					Type:       EventToolResult,
+					Timestamp:  end,
+					ToolOutput: p.State.Output,
+					ToolID:     p.CallID,
+				})
+			case "error":
+				events = append(events, Event{
+					Type:       EventToolResult,
+					Timestamp:  end,
+					ToolOutput: p.State.Error,
+					IsError:    true,
+					ToolID:     p.CallID,
+				})
+			}
+
+		case "step-start", "step-finish", "file", "patch", "snapshot", "agent", "retry", "compaction", "subtask":
+			// Step markers, attachments and bookkeeping, skip.
+
+		default:
+			*diags = addDiagnostic(*diags, DiagUnknownType, 0, "part type "+p.Type)
+		}
+	}
+	return events
+}
@@ -2,268 +2,576 @@ package session
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
+// maxToolOutput is the most of a tool output kept in memory; larger outputs
+// are read back from the transcript when they are shown in full.
+const maxToolOutput = 64 << 10
+
 // ParseSessionFile reads a JSONL transcript and returns a fully parsed Session.
 func ParseSessionFile(path string) (*Session, error) {
-	f, err := os.Open(path)
-	if err != nil {
+	p := newSessionParser(path)
+	p.final = true
+	if _, err := p.update(); err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	return p.session(), nil
+}
+
+// sessionParser decodes a JSONL transcript incrementally. It remembers how far
+// into the file it has read so that lines appended by a live session can be
+// merged in without decoding everything that came before.
+type sessionParser struct {
+	path   string
+	offset int64       // bytes consumed so far, always at a line boundary
+	start  int64       // offset of the line being consumed
+	line   int         // lines consumed so far
+	file   os.FileInfo // file identity at the last read, used to detect rotation
+	final  bool        // the file is not being written, so a last line without a newline is whole
+
+	info   SessionInfo  // metadata that does not depend on the event timeline
+	blocks []eventBlock // events grouped by the entry that produced them, in file order
+
+	// Claude Code rewrites an assistant message as it streams, reusing its ID.
+	// Only the most complete version is kept; this maps message ID → block index.
+	assistant map[string]int
+
+	// Every entry's parent link, for building the conversation tree
+	tree      map[string]treeEntry
+	treeOrder []string
+
+	agentCalls map[string]string // subagent ID → ToolID of the Task call that spawned it
+
+	checkpoints   []rawCheckpoint
+	checkpointIdx map[string]int // message ID → index into checkpoints
+
+	reported int // blocks already reported to subscribers as appended
+}
 
-	basename := filepath.Base(path)
-	sessionID := strings.TrimSuffix(basename, ".jsonl")
-	isAgent := strings.HasPrefix(basename, "agent-")
+// eventBlock holds the events produced by a single transcript entry.
+type eventBlock struct {
+	events    []Event
+	messageID string // assistant message ID, empty for other entries
+	model     string // model that produced the assistant message
+	timestamp time.Time
+	usage     *rawUsage
+	dropped   bool // superseded by a later version of the same assistant message
+}
 
-	// Decode the project path from the directory name
-	dirName := filepath.Base(filepath.Dir(path))
-	projectDir := strings.ReplaceAll(dirName, "-", "/")
-	projectName := filepath.Base(projectDir)
+func newSessionParser(path string) *sessionParser {
+	p := &sessionParser{path: path}
+	p.reset()
+	return p
+}
 
-	sess := &Session{
-		Info: SessionInfo{
-			ID:          sessionID,
-			ProjectDir:  projectDir,
-			ProjectName: projectName,
-			FilePath:    path,
-			IsAgent:     isAgent,
-		},
+// reset discards all parser state so the next update re-reads the file from the start.
+func (p *sessionParser) reset() {
+	basename := filepath.Base(p.path)
+
+	p.offset = 0
+	p.line = 0
+	p.file = nil
+	p.blocks = nil
+	p.reported = 0
+	p.assistant = make(map[string]int)
+	p.tree = make(map[string]treeEntry)
+	p.treeOrder = nil
+	p.agentCalls = make(map[string]string)
+	p.checkpoints = nil
+	p.checkpointIdx = make(map[string]int)
+	p.info = SessionInfo{
+		ID:       strings.TrimSuffix(basename, ".jsonl"),
+		FilePath: p.path,
+		IsAgent:  strings.HasPrefix(basename, "agent-"),
+		Source:   "claude",
 	}
+	if p.info.IsAgent {
+		p.info.AgentID = strings.TrimPrefix(p.info.ID, "agent-")
+	}
+}
 
-	// Track which assistant message IDs we've seen so we only keep the last (most complete) version.
-	type assistantGroup struct {
-		entry     rawEntry
-		timestamp time.Time
+// update decodes any lines appended since the previous call and reports whether
+// anything new was read. If the file shrank or was replaced, it starts over.
+func (p *sessionParser) update() (bool, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return false, err
 	}
-	assistantMessages := make(map[string]*assistantGroup)
+	defer f.Close()
 
-	var allEntries []rawEntry
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
 
-	// Track unique files
-	filesRead := make(map[string]bool)
-	filesWritten := make(map[string]bool)
-	filesCreated := make(map[string]bool)
+	// Truncation or rotation — fall back to a full re-parse.
+	if p.file != nil && (fi.Size() < p.offset || !os.SameFile(p.file, fi)) {
+		p.reset()
+	}
+	p.file = fi
 
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 0, 1024*1024), 10*1024*1024) // 10MB max line
+	if fi.Size() == p.offset {
+		return false, nil
+	}
+	if _, err := f.Seek(p.offset, io.SeekStart); err != nil {
+		return false, err
+	}
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+	changed := false
+	reader := bufio.NewReaderSize(f, 1024*1024)
+	var line []byte
+
+	for {
+		line, err = readFullLine(reader, line)
+		p.start = p.offset
+		if err == io.EOF {
+			// A trailing line without a newline may still be mid-write, even
+			// if it decodes, so it waits for the update after its newline.
+			// Only in a file that is not being written is it whole.
+			if len(line) > 0 && p.final {
+				p.offset += int64(len(line))
+				if p.consume(line) {
+					changed = true
+				}
+			}
+			break
+		}
+		if err != nil {
+			return changed, err
 		}
 
-		var entry rawEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
-			continue
+		p.offset += int64(len(line))
+		if p.consume(line) {
+			changed = true
 		}
+	}
 
-		allEntries = append(allEntries, entry)
+	return changed, nil
+}
 
-		// Capture metadata from first entry with data
-		if sess.Info.CWD == "" && entry.CWD != "" {
-			sess.Info.CWD = entry.CWD
+// readFullLine reads the next line of any length, newline included, into buf's
+// storage, growing it as needed. Reusing buf across calls keeps a transcript
+// with giant lines from allocating a new buffer for each one.
+func readFullLine(r *bufio.Reader, buf []byte) ([]byte, error) {
+	buf = buf[:0]
+	for {
+		chunk, err := r.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if err != bufio.ErrBufferFull {
+			return buf, err
 		}
+	}
+}
 
-		switch entry.Type {
-		case "assistant":
-			if entry.Message != nil && entry.Message.ID != "" {
-				ts := parseTimestamp(entry.Timestamp)
-				existing, ok := assistantMessages[entry.Message.ID]
-				if !ok || ts.After(existing.timestamp) {
-					assistantMessages[entry.Message.ID] = &assistantGroup{
-						entry:     entry,
-						timestamp: ts,
-					}
-				}
-				if sess.Info.Model == "" && entry.Message.Model != "" {
-					sess.Info.Model = entry.Message.Model
-				}
-			}
-		case "user":
-			// Skip compact summary user messages from the first pass
-		case "queue-operation", "file-history-snapshot":
-			// Low-value metadata — skip entirely
-		}
+// consume decodes a single JSONL line into the parser state. The line is
+// counted, and recorded as malformed if it does not decode.
+func (p *sessionParser) consume(line []byte) bool {
+	line = bytes.TrimSpace(line)
+
+	var entry rawEntry
+	err := json.Unmarshal(line, &entry)
+	p.line++
+
+	if len(line) == 0 {
+		return false
+	}
+	if err != nil {
+		p.diagnose(DiagMalformed, err.Error())
+		return false
 	}
 
-	// Build the event timeline.
-	seenMessageIDs := make(map[string]bool)
+	p.addEntry(entry)
+	return true
+}
+
+// diagnose records a problem with the line just consumed.
+func (p *sessionParser) diagnose(kind DiagnosticKind, detail string) {
+	p.info.Diagnostics = addDiagnostic(p.info.Diagnostics, kind, p.line, detail)
+}
 
-	for _, entry := range allEntries {
-		ts := parseTimestamp(entry.Timestamp)
+func (p *sessionParser) addEntry(entry rawEntry) {
+	ts := parseTimestamp(entry.Timestamp)
 
-		if sess.Info.StartTime.IsZero() || (!ts.IsZero() && ts.Before(sess.Info.StartTime)) {
-			sess.Info.StartTime = ts
+	if p.info.StartTime.IsZero() || (!ts.IsZero() && ts.Before(p.info.StartTime)) {
+		p.info.StartTime = ts
+	}
+	if ts.After(p.info.LastUpdate) {
+		p.info.LastUpdate = ts
+	}
+
+	// Capture metadata from first entry with data
+	if p.info.CWD == "" && entry.CWD != "" {
+		p.info.CWD = entry.CWD
+		p.info.ProjectDir = projectDirFromCWD(entry.CWD, projectDirName(p.path))
+	}
+
+	if entry.GitBranch != "" && !slices.Contains(p.info.GitBranches, entry.GitBranch) {
+		p.info.GitBranches = append(p.info.GitBranches, entry.GitBranch)
+	}
+	if entry.Version != "" && !slices.Contains(p.info.Versions, entry.Version) {
+		p.info.Versions = append(p.info.Versions, entry.Version)
+	}
+	if entry.IsSidechain {
+		p.info.IsSidechain = true
+	}
+
+	// Subagent transcripts record the parent's session ID on every entry
+	if p.info.IsAgent && p.info.ParentID == "" && entry.SessionID != "" && entry.SessionID != p.info.ID {
+		p.info.ParentID = entry.SessionID
+		if entry.AgentID != "" {
+			p.info.AgentID = entry.AgentID
+		}
+	}
+
+	if _, seen := p.tree[entry.UUID]; entry.UUID != "" && !seen {
+		parent := ""
+		if entry.ParentUUID != nil {
+			parent = *entry.ParentUUID
+		} else if entry.LogicalParentUUID != nil {
+			parent = *entry.LogicalParentUUID
 		}
-		if ts.After(sess.Info.LastUpdate) {
-			sess.Info.LastUpdate = ts
+		p.tree[entry.UUID] = treeEntry{
+			parent:    parent,
+			message:   entry.Type == "user" || entry.Type == "assistant",
+			timestamp: ts,
 		}
+		p.treeOrder = append(p.treeOrder, entry.UUID)
+	}
 
-		switch entry.Type {
-		case "system":
-			if entry.Subtype == "compact_boundary" {
-				preTokens := 0
-				trigger := ""
-				if entry.CompactMetadata != nil {
-					preTokens = entry.CompactMetadata.PreTokens
-					trigger = entry.CompactMetadata.Trigger
-				}
-				sess.Events = append(sess.Events, Event{
-					Type:             EventCompaction,
-					Timestamp:        ts,
-					UUID:             entry.UUID,
-					CompactPreTokens: preTokens,
-					CompactTrigger:   trigger,
-				})
-			} else if entry.Subtype == "turn_duration" {
-				sess.Events = append(sess.Events, Event{
-					Type:           EventTurnDuration,
-					Timestamp:      ts,
-					UUID:           entry.UUID,
-					TurnDurationMs: entry.DurationMs,
-				})
+	switch entry.Type {
+	case "system":
+		if entry.Subtype == "compact_boundary" {
+			preTokens := 0
+			trigger := ""
+			if entry.CompactMetadata != nil {
+				preTokens = entry.CompactMetadata.PreTokens
+				trigger = entry.CompactMetadata.Trigger
 			}
+			p.appendEvents(Event{
+				Type:             EventCompaction,
+				Timestamp:        ts,
+				UUID:             entry.UUID,
+				CompactPreTokens: preTokens,
+				CompactTrigger:   trigger,
+			})
+		} else if entry.Subtype == "turn_duration" {
+			p.appendEvents(Event{
+				Type:           EventTurnDuration,
+				Timestamp:      ts,
+				UUID:           entry.UUID,
+				TurnDurationMs: entry.DurationMs,
+			})
+		}
 
-		case "progress":
-			if len(entry.Data) > 0 {
-				var pd rawProgressData
-				if err := json.Unmarshal(entry.Data, &pd); err == nil {
-					switch pd.Type {
-					case "agent_progress", "waiting_for_task":
-						desc := pd.TaskDescription
-						if desc == "" {
-							desc = pd.Prompt
-						}
-						if len(desc) > 120 {
-							desc = desc[:117] + "..."
-						}
-						sess.Events = append(sess.Events, Event{
-							Type:             EventAgentProgress,
-							Timestamp:        ts,
-							UUID:             entry.UUID,
-							AgentID:          pd.AgentID,
-							AgentDescription: desc,
-						})
-					case "hook_progress":
-						sess.Events = append(sess.Events, Event{
-							Type:      EventHookProgress,
-							Timestamp: ts,
-							UUID:      entry.UUID,
-							HookEvent: pd.HookEvent,
-							HookName:  pd.HookName,
+	case "progress":
+		if len(entry.Data) > 0 {
+			var pd rawProgressData
+			if err := json.Unmarshal(entry.Data, &pd); err != nil {
+				p.diagnose(DiagSchema, "progress data: "+err.Error())
+			} else {
+				switch pd.Type {
+				case "agent_progress", "waiting_for_task":
+					if pd.AgentID != "" && entry.ParentToolUseID != "" {
+						p.agentCalls[pd.AgentID] = entry.ParentToolUseID
+					}
+					desc := pd.TaskDescription
+					if desc == "" {
+						desc = pd.Prompt
+					}
+					if len(desc) > 120 {
+						desc = desc[:117] + "..."
+					}
+					p.appendEvents(Event{
+						Type:             EventAgentProgress,
+						Timestamp:        ts,
+						UUID:             entry.UUID,
+						AgentID:          pd.AgentID,
+						AgentDescription: desc,
+					})
+				case "hook_progress":
+					p.appendEvents(Event{
+						Type:      EventHookProgress,
+						Timestamp: ts,
+						UUID:      entry.UUID,
+						HookEvent: pd.HookEvent,
+						HookName:  pd.HookName,
+					})
+				case "bash_progress":
+					output := pd.FullOutput
+					if output == "" {
+						output = pd.Output
+					}
+					if strings.TrimSpace(output) != "" || pd.ElapsedTimeSec > 0 {
+						p.appendEvents(Event{
+							Type:           EventBashProgress,
+							Timestamp:      ts,
+							UUID:           entry.UUID,
+							ToolID:         entry.ParentToolUseID,
+							BashElapsedSec: pd.ElapsedTimeSec,
+							BashOutput:     output,
 						})
-					case "bash_progress":
-						if strings.TrimSpace(pd.Output) != "" || pd.ElapsedTimeSec > 0 {
-							sess.Events = append(sess.Events, Event{
-								Type:           EventBashProgress,
-								Timestamp:      ts,
-								UUID:           entry.UUID,
-								BashElapsedSec: pd.ElapsedTimeSec,
-							})
-						}
 					}
 				}
 			}
+		}
 
-		case "queue-operation", "file-history-snapshot":
-			// Low-value metadata — skip
+	case "file-history-snapshot":
+		p.addSnapshot(entry, ts)
 
-		case "user":
-			if entry.Message == nil {
-				continue
-			}
-			// Skip compact summary messages - they are injected context, not real user messages
-			if entry.IsCompactSummary {
-				continue
-			}
-			events := parseUserMessage(entry, ts)
-			sess.Events = append(sess.Events, events...)
+	case "queue-operation", "summary", "custom-title":
+		// Low-value metadata — skip
 
-			// Count user prompts (only text ones, not tool results)
+	case "user":
+		if entry.Message == nil {
+			p.diagnose(DiagSchema, "user entry without a message")
+			return
+		}
+		// Skip compact summary messages - they are injected context, not real user messages
+		if entry.IsCompactSummary {
+			return
+		}
+		events := parseUserMessage(entry, ts)
+		p.preview(events)
+		p.appendEvents(events...)
+
+		// Task results name the subagent that ran the task
+		if agentID := toolResultAgentID(entry.ToolUseResult); agentID != "" {
 			for _, e := range events {
-				if e.Type == EventUserPrompt {
-					sess.Info.UserPrompts++
-				}
-				if e.Type == EventToolResult && e.IsError {
-					sess.Info.Errors++
+				if e.Type == EventToolResult && e.ToolID != "" {
+					p.agentCalls[agentID] = e.ToolID
 				}
 			}
+		}
 
-		case "assistant":
-			if entry.Message == nil || entry.Message.ID == "" {
-				continue
-			}
-			if seenMessageIDs[entry.Message.ID] {
-				continue
-			}
-			final, ok := assistantMessages[entry.Message.ID]
-			if !ok {
-				continue
+	case "assistant":
+		if entry.Message == nil || entry.Message.ID == "" {
+			p.diagnose(DiagSchema, "assistant entry without a message ID")
+			return
+		}
+		if p.info.Model == "" && entry.Message.Model != "" {
+			p.info.Model = entry.Message.Model
+		}
+
+		// Keep only the latest version of each message. A newer version
+		// replaces the old one and takes its place in the timeline.
+		id := entry.Message.ID
+		if i, ok := p.assistant[id]; ok {
+			if !ts.After(p.blocks[i].timestamp) {
+				return
 			}
-			if entry.UUID != final.entry.UUID {
-				continue
+			p.blocks[i].dropped = true
+			p.blocks[i].events = nil
+		}
+		p.assistant[id] = len(p.blocks)
+		p.blocks = append(p.blocks, eventBlock{
+			events:    parseAssistantMessage(entry, ts),
+			messageID: id,
+			model:     entry.Message.Model,
+			timestamp: ts,
+			usage:     entry.Message.Usage,
+		})
+
+	case "":
+		p.diagnose(DiagSchema, "entry without a type")
+
+	default:
+		p.diagnose(DiagUnknownType, entry.Type)
+	}
+}
+
+// preview cuts tool outputs over maxToolOutput down to their start, recording
+// where the full output can be read back from with readToolOutput.
+func (p *sessionParser) preview(events []Event) {
+	for i := range events {
+		e := &events[i]
+		if e.Type != EventToolResult || len(e.ToolOutput) <= maxToolOutput {
+			continue
+		}
+		n := maxToolOutput
+		for n > 0 && !utf8.RuneStart(e.ToolOutput[n]) {
+			n--
+		}
+		e.OutputSize = len(e.ToolOutput)
+		e.OutputOffset = p.start
+		// Cloned so the full output is not kept alive behind the preview
+		e.ToolOutput = strings.Clone(e.ToolOutput[:n])
+	}
+}
+
+// readToolOutput reads back the full output of a tool result that was cut to
+// a preview, from the transcript line at offset.
+func readToolOutput(path string, offset int64, toolID string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	line, err := readFullLine(bufio.NewReaderSize(f, 1024*1024), nil)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	var entry rawEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return "", err
+	}
+	if entry.Message != nil {
+		for _, e := range parseUserMessage(entry, time.Time{}) {
+			if e.Type == EventToolResult && e.ToolID == toolID {
+				return e.ToolOutput, nil
 			}
-			seenMessageIDs[entry.Message.ID] = true
-			events := parseAssistantMessage(final.entry, ts)
-			sess.Events = append(sess.Events, events...)
+		}
+	}
+	return "", fmt.Errorf("tool result %s not found at offset %d of %s", toolID, offset, path)
+}
 
-			// Track file operations and tool stats
-			for _, e := range events {
-				if e.Type == EventToolUse {
-					sess.Info.ToolCallCount++
+func (p *sessionParser) appendEvents(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	p.blocks = append(p.blocks, eventBlock{events: events})
+}
 
-					switch e.ToolName {
-					case "Read":
-						if fp, ok := e.ToolInput["file_path"].(string); ok {
-							filesRead[fp] = true
-						}
-					case "Write":
-						if fp, ok := e.ToolInput["file_path"].(string); ok {
-							filesCreated[fp] = true
-						}
-					case "Edit":
-						if fp, ok := e.ToolInput["file_path"].(string); ok {
-							filesWritten[fp] = true
-						}
-					case "Bash":
-						sess.Info.BashCommands++
-					}
+// takeAppended returns the events of blocks added since the previous call.
+// Blocks are only ever appended, so these are the tail of sess.Events. The
+// tail is clipped, so appending to it cannot write into sess.Events.
+func (p *sessionParser) takeAppended(sess *Session) []Event {
+	before := 0
+	for _, blk := range p.blocks[:min(p.reported, len(p.blocks))] {
+		if !blk.dropped {
+			before += len(blk.events)
+		}
+	}
+	p.reported = len(p.blocks)
+	if before >= len(sess.Events) {
+		return nil
+	}
+	return slices.Clip(sess.Events[before:])
+}
+
+// session assembles a Session snapshot from the current parser state. The
+// returned Session does not share its event slice with the parser, so it stays
+// valid while later updates are applied.
+func (p *sessionParser) session() *Session {
+	sess := &Session{Info: p.info}
+	sess.Info.Diagnostics = slices.Clone(p.info.Diagnostics)
+
+	// Without a recorded cwd, fall back to decoding the project directory name
+	if sess.Info.ProjectDir == "" {
+		sess.Info.ProjectDir = decodeProjectDir(projectDirName(p.path))
+	}
+
+	// Remember which Task call spawned each subagent
+	spawnedBy := make(map[string]string) // ToolID → agent ID
+	if len(p.agentCalls) > 0 {
+		sess.Info.AgentCalls = make(map[string]string, len(p.agentCalls))
+		for agentID, toolID := range p.agentCalls {
+			sess.Info.AgentCalls[agentID] = toolID
+			spawnedBy[toolID] = agentID
+		}
+	}
+	sess.Info.ProjectName = filepath.Base(sess.Info.ProjectDir)
+
+	// Track unique files
+	files := newFileStats()
+	unpriced := make(map[string]bool)
+
+	for _, blk := range p.blocks {
+		if blk.dropped {
+			continue
+		}
+		sess.Events = append(sess.Events, blk.events...)
+		if len(spawnedBy) > 0 {
+			for i := len(sess.Events) - len(blk.events); i < len(sess.Events); i++ {
+				if e := &sess.Events[i]; e.Type == EventToolUse && spawnedBy[e.ToolID] != "" {
+					e.AgentID = spawnedBy[e.ToolID]
+				}
+			}
+		}
+
+		// Track file operations and tool stats
+		for _, e := range blk.events {
+			switch e.Type {
+			case EventUserPrompt:
+				sess.Info.UserPrompts++
+			case EventToolResult:
+				if e.IsError {
+					sess.Info.Errors++
 				}
+			case EventToolUse:
+				sess.Info.ToolCallCount++
+				files.add(e)
 			}
+		}
 
-			// Accumulate token usage
-			if final.entry.Message.Usage != nil {
-				u := final.entry.Message.Usage
-				sess.Info.InputTokens += u.InputTokens
-				sess.Info.OutputTokens += u.OutputTokens
-				sess.Info.CacheReadTokens += u.CacheReadInputTokens
-				sess.Info.CacheWriteTokens += u.CacheCreationInputTokens
+		// Accumulate token usage
+		if u := blk.usage; u != nil {
+			sess.Info.InputTokens += u.InputTokens
+			sess.Info.OutputTokens += u.OutputTokens
+			sess.Info.CacheReadTokens += u.CacheReadInputTokens
+			sess.Info.CacheWriteTokens += u.CacheCreationInputTokens
+
+			// Price each message at its own model's rate
+			if price, ok := priceFor(blk.model); ok {
+				sess.Info.CostUSD += price.cost(u)
+			} else {
+				unpriced[blk.model] = true
 			}
 		}
 	}
 
-	// Convert file maps to slices
-	for fp := range filesRead {
-		sess.Info.FilesRead = append(sess.Info.FilesRead, fp)
+	files.apply(&sess.Info)
+	for model := range unpriced {
+		if model == "" {
+			model = "unknown"
+		}
+		sess.Info.UnpricedModels = append(sess.Info.UnpricedModels, model)
 	}
-	for fp := range filesWritten {
-		sess.Info.FilesWritten = append(sess.Info.FilesWritten, fp)
+	sort.Strings(sess.Info.UnpricedModels)
+
+	// Checkpoints are backed up under the top-level session's ID
+	sessionID := sess.Info.ID
+	if sess.Info.ParentID != "" {
+		sessionID = sess.Info.ParentID
 	}
-	for fp := range filesCreated {
-		sess.Info.FilesCreated = append(sess.Info.FilesCreated, fp)
+	sess.Checkpoints = buildCheckpoints(p.checkpoints, sessionID, sess.Info.CWD, sess.Events)
+
+	// Place each event in the conversation tree
+	sess.Tree = buildTree(p.tree, p.treeOrder)
+	nodes := make(map[string]string)
+	for i := range sess.Events {
+		e := &sess.Events[i]
+		if e.UUID == "" {
+			continue
+		}
+		node, ok := nodes[e.UUID]
+		if !ok {
+			node = messageAncestor(p.tree, e.UUID)
+			nodes[e.UUID] = node
+		}
+		e.Node = node
 	}
 
+	linkToolCalls(sess.Events)
 	sess.Info.EventCount = len(sess.Events)
-	sess.Info.CostUSD = estimateCost(sess.Info)
 
-	return sess, nil
+	return sess
 }
 
 func parseUserMessage(entry rawEntry, ts time.Time) []Event {
@@ -412,6 +720,97 @@ func parseAssistantMessage(entry rawEntry, ts time.Time) []Event {
 	return events
 }
 
+// projectDirName returns the encoded project directory a transcript belongs to.
+// Subagent transcripts may live in <project>/<session-id>/subagents/.
+func projectDirName(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "subagents" {
+		dir = filepath.Dir(filepath.Dir(dir))
+	}
+	return filepath.Base(dir)
+}
+
+// toolResultAgentID extracts the subagent ID from a Task tool result's
+// toolUseResult metadata, which is an object for Task calls.
+func toolResultAgentID(raw json.RawMessage) string {
+	if len(raw) == 0 || raw[0] != '{' {
+		return ""
+	}
+	var r struct {
+		AgentID string `json:"agentId"`
+	}
+	if json.Unmarshal(raw, &r) != nil {
+		return ""
+	}
+	return r.AgentID
+}
+
+// encodeProjectDir mirrors how Claude Code names project directories under
+// ~/.claude/projects: every character other than a letter or digit becomes a dash.
+func encodeProjectDir(path string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, path)
+}
+
+// projectDirFromCWD returns the project path for a session whose transcript
+// lives in the encoded directory dirName. The cwd recorded in the transcript
+// is usually the project root, but may be a subdirectory of it.
+func projectDirFromCWD(cwd, dirName string) string {
+	for dir := filepath.Clean(cwd); ; dir = filepath.Dir(dir) {
+		if encodeProjectDir(dir) == dirName {
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return decodeProjectDir(dirName)
+}
+
+// decodedDirs memoizes decodeProjectDir, which may read the filesystem.
+var decodedDirs sync.Map
+
+// decodeProjectDir recovers a project path from an encoded directory name.
+// The encoding is lossy, so path components that exist on disk are matched
+// against it; if that fails every dash is treated as a path separator.
+func decodeProjectDir(dirName string) string {
+	if dir, ok := decodedDirs.Load(dirName); ok {
+		return dir.(string)
+	}
+
+	dir, ok := resolveEncodedPath(string(filepath.Separator), strings.TrimPrefix(dirName, "-"))
+	if !ok {
+		dir = strings.ReplaceAll(dirName, "-", "/")
+	}
+	decodedDirs.Store(dirName, dir)
+	return dir
+}
+
+// resolveEncodedPath finds a path below base whose encoding is rest.
+func resolveEncodedPath(base, rest string) (string, bool) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return "", false
+	}
+
+	for _, e := range entries {
+		enc := encodeProjectDir(e.Name())
+		if rest == enc {
+			return filepath.Join(base, e.Name()), true
+		}
+		if e.IsDir() && strings.HasPrefix(rest, enc+"-") {
+			if dir, ok := resolveEncodedPath(filepath.Join(base, e.Name()), rest[len(enc)+1:]); ok {
+				return dir, true
+			}
+		}
+	}
+	return "", false
+}
+
 func parseTimestamp(s string) time.Time {
 	t, err := time.Parse(time.RFC3339Nano, s)
 	if err != nil {
@@ -419,17 +818,3 @@ func parseTimestamp(s string) time.Time {
 	}
 	return t
 }
-
-// estimateCost gives a rough USD cost estimate based on Claude pricing.
-// Uses Opus pricing: $15/M input, $75/M output, cache read $1.5/M, cache write $18.75/M
-func estimateCost(info SessionInfo) float64 {
-	inputPrice := 15.0 / 1_000_000.0
-	outputPrice := 75.0 / 1_000_000.0
-	cacheReadPrice := 1.5 / 1_000_000.0
-	cacheWritePrice := 18.75 / 1_000_000.0
-
-	return float64(info.InputTokens)*inputPrice +
-		float64(info.OutputTokens)*outputPrice +
-		float64(info.CacheReadTokens)*cacheReadPrice +
-		float64(info.CacheWriteTokens)*cacheWritePrice
-}
@@ -0,0 +1,153 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func promptLine(uuid, text string) string {
+	return fmt.Sprintf(`{"type":"user","uuid":%q,"timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":%q}}`+"\n", uuid, text)
+}
+
+func TestSessionParserUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sess.jsonl")
+
+	appendFile := func(data string) func(t *testing.T) {
+		return func(t *testing.T) {
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	overwrite := func(data string) func(t *testing.T) {
+		return func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	replace := func(data string) func(t *testing.T) {
+		return func(t *testing.T) {
+			tmp := path + ".tmp"
+			if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Steps run in order against the same parser, as a watcher would drive it.
+	steps := []struct {
+		name        string
+		write       func(t *testing.T)
+		changed     bool
+		prompts     int
+		appended    int
+		malformedAt []int // lines recorded as malformed
+	}{
+		{"initial", appendFile(promptLine("u1", "one") + promptLine("u2", "two")), true, 2, 2, nil},
+		{"unchanged", func(*testing.T) {}, false, 2, 0, nil},
+		{"appended line", appendFile(promptLine("u3", "three")), true, 3, 1, nil},
+		{"partial line", appendFile(`{"type":"user","uuid":"u4",`), false, 3, 0, nil},
+		{"partial line completed", appendFile(`"timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"four"}}` + "\n"), true, 4, 1, nil},
+		// A line that decodes is still mid-write until its newline arrives
+		{"whole line without newline", appendFile(strings.TrimSuffix(promptLine("u5", "five"), "\n")), false, 4, 0, nil},
+		{"newline written", appendFile("\n"), true, 5, 1, nil},
+		{"malformed line", appendFile("not json\n"), false, 5, 0, []int{6}},
+		{"truncated", overwrite(promptLine("u6", "six")), true, 1, 1, nil},
+		{"appended after truncation", appendFile(promptLine("u7", "seven")), true, 2, 1, nil},
+		{"replaced", replace(promptLine("u8", "eight") + promptLine("u9", "nine") + promptLine("u10", "ten")), true, 3, 3, nil},
+	}
+
+	p := newSessionParser(path)
+	for _, step := range steps {
+		step.write(t)
+
+		changed, err := p.update()
+		if err != nil {
+			t.Fatalf("%s: update: %v", step.name, err)
+		}
+		sess := p.session()
+		appended := p.takeAppended(sess)
+
+		if changed != step.changed {
+			t.Errorf("%s: changed = %v, want %v", step.name, changed, step.changed)
+		}
+		if sess.Info.UserPrompts != step.prompts {
+			t.Errorf("%s: UserPrompts = %d, want %d", step.name, sess.Info.UserPrompts, step.prompts)
+		}
+		if len(appended) != step.appended {
+			t.Errorf("%s: %d events appended, want %d", step.name, len(appended), step.appended)
+		}
+		var malformedAt []int
+		for _, d := range sess.Info.Diagnostics {
+			if d.Kind == DiagMalformed {
+				malformedAt = append(malformedAt, d.Lines...)
+			}
+		}
+		if !slices.Equal(malformedAt, step.malformedAt) {
+			t.Errorf("%s: malformed lines %v, want %v", step.name, malformedAt, step.malformedAt)
+		}
+	}
+}
+
+func TestParseSessionFileFinalLine(t *testing.T) {
+	// A finished transcript's last line is read even without a newline
+	path := filepath.Join(t.TempDir(), "sess.jsonl")
+	data := promptLine("u1", "one") + strings.TrimSuffix(promptLine("u2", "two"), "\n")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := ParseSessionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Info.UserPrompts != 2 {
+		t.Errorf("UserPrompts = %d, want 2", sess.Info.UserPrompts)
+	}
+}
+
+func TestTakeAppendedIsClipped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sess.jsonl")
+	if err := os.WriteFile(path, []byte(promptLine("u1", "one")+promptLine("u2", "two")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newSessionParser(path)
+	if _, err := p.update(); err != nil {
+		t.Fatal(err)
+	}
+	sess := p.session()
+	p.takeAppended(sess)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(promptLine("u3", "three"))
+	f.Close()
+	if _, err := p.update(); err != nil {
+		t.Fatal(err)
+	}
+	sess = p.session()
+	appended := p.takeAppended(sess)
+	// Spare capacity would let a subscriber's append write into sess.Events
+	if len(appended) != 1 || cap(appended) != 1 {
+		t.Fatalf("appended len %d cap %d, want 1 and 1", len(appended), cap(appended))
+	}
+	if appended[0].UserText != "three" {
+		t.Errorf("appended %q, want %q", appended[0].UserText, "three")
+	}
+}
@@ -0,0 +1,144 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// modelPrice is the USD price per million tokens for a model.
+type modelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read"`
+	CacheWrite float64 `json:"cache_write"`
+}
+
+// defaultPrices holds list prices for Claude models and the OpenAI and Gemini
+// models Codex and Gemini CLI run, keyed by model ID. Dated snapshots
+// (claude-sonnet-4-5-20250929, gpt-5-2025-08-07) use their model's price, but
+// each minor version is listed on its own, so a new one shows as unpriced
+// rather than at an older version's rate. OpenAI does not charge for cache
+// writes, and Gemini prices are those for prompts up to 200k tokens.
+var defaultPrices = map[string]modelPrice{
+	"claude-opus-4":     {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-opus-4-1":   {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-opus-4-5":   {Input: 5, Output: 25, CacheRead: 0.5, CacheWrite: 6.25},
+	"claude-opus-4-6":   {Input: 5, Output: 25, CacheRead: 0.5, CacheWrite: 6.25},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-sonnet-4-5": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-haiku-4-5":  {Input: 1, Output: 5, CacheRead: 0.1, CacheWrite: 1.25},
+	"claude-3-opus":     {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.3},
+
+	"gpt-5":             {Input: 1.25, Output: 10, CacheRead: 0.125},
+	"gpt-5-codex":       {Input: 1.25, Output: 10, CacheRead: 0.125},
+	"gpt-5-mini":        {Input: 0.25, Output: 2, CacheRead: 0.025},
+	"gpt-5-nano":        {Input: 0.05, Output: 0.4, CacheRead: 0.005},
+	"codex-mini-latest": {Input: 1.5, Output: 6, CacheRead: 0.375},
+	"o3":                {Input: 2, Output: 8, CacheRead: 0.5},
+	"o4-mini":           {Input: 1.1, Output: 4.4, CacheRead: 0.275},
+
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10, CacheRead: 0.125},
+	"gemini-2.5-flash":      {Input: 0.3, Output: 2.5, CacheRead: 0.03},
+	"gemini-2.5-flash-lite": {Input: 0.1, Output: 0.4, CacheRead: 0.01},
+
+	// Claude Code writes locally generated messages (errors, interrupts) with this model.
+	"<synthetic>": {},
+}
+
+var (
+	pricesOnce sync.Once
+	prices     map[string]modelPrice
+	pricesErr  error
+)
+
+// modelDate matches the date suffix of a model snapshot ID, in Anthropic's
+// (-20250929) or OpenAI's (-2025-08-07) form.
+var modelDate = regexp.MustCompile(`-(\d{8}|\d{4}-\d{2}-\d{2})$`)
+
+// pricingPath returns the location of the user's price overrides,
+// e.g. ~/.config/verbose/pricing.json. The file maps model IDs to prices per
+// million tokens and is merged over the defaults.
+func pricingPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "verbose", "pricing.json"), nil
+}
+
+// loadPrices returns the default price table merged with the user's overrides.
+// Overrides that do not decode are ignored and reported by PricingError.
+func loadPrices() map[string]modelPrice {
+	pricesOnce.Do(func() {
+		prices = make(map[string]modelPrice, len(defaultPrices))
+		for id, p := range defaultPrices {
+			prices[id] = p
+		}
+
+		path, err := pricingPath()
+		if err != nil {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		var overrides map[string]modelPrice
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			pricesErr = fmt.Errorf("%s: %w", path, err)
+			return
+		}
+		for id, p := range overrides {
+			prices[id] = p
+		}
+	})
+	return prices
+}
+
+// PricingError returns the error that made the user's price overrides be
+// ignored, or nil if they loaded or there are none.
+func PricingError() error {
+	loadPrices()
+	return pricesErr
+}
+
+// pricesHash identifies the loaded price table, so costs cached under a
+// different one are recomputed.
+func pricesHash() string {
+	// Maps marshal with sorted keys, so equal tables hash alike
+	data, _ := json.Marshal(loadPrices())
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// priceFor looks up the price for a model ID, falling back to the ID without
+// its snapshot date.
+func priceFor(model string) (modelPrice, bool) {
+	table := loadPrices()
+	if p, ok := table[model]; ok {
+		return p, true
+	}
+	if base := modelDate.ReplaceAllString(model, ""); base != model {
+		p, ok := table[base]
+		return p, ok
+	}
+	return modelPrice{}, false
+}
+
+// cost returns the USD cost of a single message's token usage.
+func (p modelPrice) cost(u *rawUsage) float64 {
+	return (float64(u.InputTokens)*p.Input +
+		float64(u.OutputTokens)*p.Output +
+		float64(u.CacheReadInputTokens)*p.CacheRead +
+		float64(u.CacheCreationInputTokens)*p.CacheWrite) / 1_000_000.0
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// usePriceOverrides points the user config directory at a temporary one
+// holding overrides as pricing.json (none if empty), and makes the next
+// lookup load prices afresh.
+func usePriceOverrides(t *testing.T, overrides string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+	if overrides != "" {
+		path := filepath.Join(dir, "verbose", "pricing.json")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(overrides), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reset := func() {
+		pricesOnce = sync.Once{}
+		prices, pricesErr = nil, nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestPriceFor(t *testing.T) {
+	usePriceOverrides(t, "")
+
+	tests := []struct {
+		model string
+		input float64 // 0 when unpriced
+		ok    bool
+	}{
+		{"claude-opus-4-6", 5, true},
+		{"claude-opus-4-1-20250805", 15, true},
+		{"claude-opus-4-20250514", 15, true},
+		{"claude-sonnet-4-5-20250929", 3, true},
+		{"claude-haiku-4-5-20251001", 1, true},
+		{"gpt-5-2025-08-07", 1.25, true},
+		{"gpt-5-mini-2025-08-07", 0.25, true},
+		{"gpt-5-codex", 1.25, true},
+		{"gemini-2.5-flash-lite", 0.1, true},
+
+		// A newer minor version is not priced at an older one's rate
+		{"claude-opus-4-7", 0, false},
+		{"claude-sonnet-4-6-20260101", 0, false},
+		// Only a date suffix is stripped
+		{"claude-opus-4-5-fast", 0, false},
+		{"gpt-5-turbo", 0, false},
+		{"unknown-model", 0, false},
+	}
+
+	for _, tt := range tests {
+		p, ok := priceFor(tt.model)
+		if ok != tt.ok || p.Input != tt.input {
+			t.Errorf("priceFor(%q) = %v, %v; want input %v, %v", tt.model, p.Input, ok, tt.input, tt.ok)
+		}
+	}
+}
+
+func TestPriceOverrides(t *testing.T) {
+	usePriceOverrides(t, "")
+	defaults := pricesHash()
+
+	usePriceOverrides(t, `{"claude-opus-4-6": {"input": 1, "output": 2}, "my-model": {"input": 3}}`)
+	if err := PricingError(); err != nil {
+		t.Fatalf("PricingError() = %v", err)
+	}
+	if p, _ := priceFor("claude-opus-4-6-20260101"); p.Input != 1 {
+		t.Errorf("overridden model priced at %v, want 1", p.Input)
+	}
+	if p, ok := priceFor("my-model"); !ok || p.Input != 3 {
+		t.Errorf("added model = %v, %v; want 3, true", p.Input, ok)
+	}
+	if pricesHash() == defaults {
+		t.Error("overrides did not change the price table hash")
+	}
+
+	usePriceOverrides(t, `{"claude-opus-4-6": {"input": "cheap"}}`)
+	if PricingError() == nil {
+		t.Error("malformed overrides not reported")
+	}
+	if p, _ := priceFor("claude-opus-4-6"); p.Input != 5 {
+		t.Errorf("with malformed overrides, priced at %v, want the default 5", p.Input)
+	}
+	if pricesHash() != defaults {
+		t.Error("malformed overrides changed the price table hash")
+	}
+}
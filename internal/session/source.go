@@ -0,0 +1,250 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Source is one agent's transcript format: where its sessions are stored, how
+// they are parsed, how changes are noticed and how a session is resumed. Its
+// sessions carry its Name in SessionInfo.Source.
+type Source interface {
+	Name() string
+
+	// Scan discovers the source's sessions and registers them with the store.
+	Scan() error
+
+	// Watch starts reporting changes to the store in the background, until
+	// Close is called.
+	Watch()
+
+	// Load parses the full events of one of the source's sessions.
+	Load(info SessionInfo) (*Session, error)
+
+	// Resume returns the command line that continues a session in its agent.
+	Resume(info SessionInfo) (cli string, args []string)
+
+	Close() error
+}
+
+// sources lists the transcript formats a new Store reads, in scan order.
+// Sources that look for sessions next to the working directories of sessions
+// already found come after the sources that find those.
+var sources = []func(st *Store) (Source, error){
+	newClaudeSource,
+	newOpenCodeSource,
+	newCodexSource,
+	newGeminiSource,
+	newAiderSource,
+}
+
+// source returns the registered source with the given name, or nil.
+func (s *Store) source(name string) Source {
+	if name == "" {
+		// Sessions indexed before sources were named are Claude Code's
+		name = "claude"
+	}
+	for _, src := range s.sources {
+		if src.Name() == name {
+			return src
+		}
+	}
+	return nil
+}
+
+// ResumeCommand returns the command line that continues a session in the
+// agent that recorded it.
+func (s *Store) ResumeCommand(info SessionInfo) (cli string, args []string, ok bool) {
+	src := s.source(info.Source)
+	if src == nil {
+		return "", nil, false
+	}
+	cli, args = src.Resume(info)
+	return cli, args, cli != ""
+}
+
+// cached returns the indexed sessions of a file if the index entry still
+// matches it. During Scan it also records the file as present, so the index
+// keeps it.
+func (s *Store) cached(path string, fi os.FileInfo) ([]SessionInfo, bool) {
+	if s.seen != nil {
+		s.seen[path] = true
+	}
+	e := s.indexed[path]
+	if fi == nil || !e.fresh(fi) {
+		return nil, false
+	}
+	return e.infos, true
+}
+
+// isLoaded reports whether a session is in the loaded cache.
+func (s *Store) isLoaded(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loaded.contains(id)
+}
+
+// knows reports whether a session is in the list.
+func (s *Store) knows(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.infos[id]
+	return ok
+}
+
+// removeSessions drops the sessions match selects and returns them.
+func (s *Store) removeSessions(match func(SessionInfo) bool) []SessionChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []SessionChange
+	for id, info := range s.infos {
+		if !match(info) {
+			continue
+		}
+		delete(s.infos, id)
+		s.loaded.remove(id)
+		s.index.remove(info.FilePath)
+		removed = append(removed, SessionChange{Kind: SessionRemoved, ID: id, Info: info})
+	}
+	return removed
+}
+
+// removeUnder drops the sessions a source read from path, or from any file
+// beneath it when a whole directory went away, and returns them.
+func (s *Store) removeUnder(source, path string) []SessionChange {
+	prefix := path + string(filepath.Separator)
+	return s.removeSessions(func(info SessionInfo) bool {
+		return info.Source == source && (info.FilePath == path || strings.HasPrefix(info.FilePath, prefix))
+	})
+}
+
+// awaitDir calls found once dir exists, for a source whose directory is
+// missing at Scan because its agent has not been run yet. Until then the
+// nearest existing ancestor is watched, one level at a time, by a watcher of
+// its own so events elsewhere in it never reach the source.
+func (s *Store) awaitDir(dir string, found func()) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+
+	// nearest returns dir or its closest ancestor that exists
+	nearest := func() string {
+		d := dir
+		for {
+			if _, err := os.Stat(d); err == nil || filepath.Dir(d) == d {
+				return d
+			}
+			d = filepath.Dir(d)
+		}
+	}
+	// exists watches the nearest ancestor and reports whether dir is there.
+	// A level created before its parent's watch took effect is caught by
+	// looking again.
+	exists := func() bool {
+		d := nearest()
+		for d != dir {
+			_ = w.Add(d)
+			next := nearest()
+			if next == d {
+				return false
+			}
+			d = next
+		}
+		return true
+	}
+
+	if exists() {
+		w.Close()
+		found()
+		return
+	}
+
+	s.mu.Lock()
+	s.awaiting = append(s.awaiting, w)
+	s.mu.Unlock()
+
+	go func() {
+		defer w.Close()
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if event.Op&fsnotify.Create != 0 && exists() {
+					found()
+					return
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// watchFiles runs a source's fsnotify loop. Files whose name matches are
+// passed to changed once writes to them settle, new directories to dir, and
+// deleted or renamed paths to removed; the changes each returns are
+// published. It returns when w is closed.
+func (s *Store) watchFiles(w *fsnotify.Watcher, match func(name string) bool, changed, dir, removed func(path string) []SessionChange) {
+	// Debounce timers, one per file, to avoid re-reading on every write
+	debounce := make(map[string]*time.Timer)
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			path := event.Name
+
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				if t, ok := debounce[path]; ok {
+					t.Stop()
+					delete(debounce, path)
+				}
+				s.publish(removed(path)...)
+				continue
+			}
+
+			if event.Op&fsnotify.Create != 0 {
+				if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+					s.publish(dir(path)...)
+					continue
+				}
+			}
+
+			if !match(filepath.Base(path)) || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			if t, ok := debounce[path]; ok {
+				t.Stop()
+			}
+			debounce[path] = time.AfterFunc(500*time.Millisecond, func() {
+				s.publish(changed(path)...)
+			})
+
+		case _, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func sessionInfos(sessions []*Session) []SessionInfo {
+	infos := make([]SessionInfo, len(sessions))
+	for i, sess := range sessions {
+		infos[i] = sess.Info
+	}
+	return infos
+}
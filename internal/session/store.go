@@ -1,269 +1,359 @@
 package session
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-// Store manages discovery and watching of Claude Code sessions.
+// Store manages discovery and watching of sessions from every registered
+// Source.
 type Store struct {
-	mu       sync.RWMutex
-	sessions map[string]*Session // keyed by session ID
-	baseDir  string
-	watcher  *fsnotify.Watcher
-	updates  chan struct{} // signals that sessions have changed
-
-	ocDBs       map[string]time.Time // tracked OpenCode DBs: path → last mtime
-	ocExtraDBs  []string             // explicitly specified OpenCode DB paths
+	mu     sync.RWMutex
+	infos  sessionSet    // metadata for every known session, keyed by ID
+	loaded *sessionCache // fully parsed sessions, bounded LRU
+
+	sources []Source
+
+	subMu sync.Mutex
+	subs  map[*Subscription]struct{} // change subscribers, see Subscribe
+
+	index   *sessionIndex          // on-disk SessionInfo cache, nil if unavailable
+	indexed map[string]*indexEntry // index contents loaded at the start of Scan
+	seen    map[string]bool        // files found during Scan, kept in the index
+
+	unlisted map[string]sourceFile // files with problems that yielded no session, see noteFile
+	errs     map[string][]string   // source name → problems outside any one file, see noteError
+
+	awaiting []*fsnotify.Watcher // watches for source directories that do not exist yet, see awaitDir
 }
 
-// NewStore creates a session store that scans ~/.claude/projects/.
-func NewStore() (*Store, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, err
-	}
+// StoreOption configures a Store created by NewStore.
+type StoreOption func(*storeOptions)
 
-	baseDir := filepath.Join(homeDir, ".claude", "projects")
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
+type storeOptions struct {
+	noIndex bool
+}
+
+// WithoutIndex makes the store parse every file and leave the on-disk index
+// alone, neither opening, reading nor writing it.
+func WithoutIndex() StoreOption {
+	return func(o *storeOptions) { o.noIndex = true }
+}
+
+// NewStore creates a session store reading every registered source.
+func NewStore(opts ...StoreOption) (*Store, error) {
+	var o storeOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
 
 	s := &Store{
-		sessions: make(map[string]*Session),
-		baseDir:  baseDir,
-		watcher:  watcher,
-		updates:  make(chan struct{}, 1),
-		ocDBs:    make(map[string]time.Time),
+		infos:  make(sessionSet),
+		loaded: newSessionCache(defaultLoadedSessions),
+		subs:   make(map[*Subscription]struct{}),
+
+		unlisted: make(map[string]sourceFile),
+		errs:     make(map[string][]string),
+	}
+
+	for _, newSource := range sources {
+		src, err := newSource(s)
+		if err != nil {
+			for _, opened := range s.sources {
+				opened.Close()
+			}
+			return nil, err
+		}
+		s.sources = append(s.sources, src)
+	}
+
+	// The index is only a cache — without it every launch does a full parse.
+	if indexPath, err := defaultIndexPath(); err == nil && !o.noIndex {
+		if ix, err := openIndex(indexPath); err == nil {
+			s.index = ix
+		}
 	}
 
 	return s, nil
 }
 
+// RebuildIndex discards the on-disk session index so the next Scan re-parses
+// every transcript from scratch.
+func (s *Store) RebuildIndex() error {
+	return s.index.reset()
+}
+
 // AddOpenCodeDB adds an explicit OpenCode database path to scan.
 func (s *Store) AddOpenCodeDB(path string) {
-	s.ocExtraDBs = append(s.ocExtraDBs, path)
+	if oc, ok := s.source("opencode").(*openCodeSource); ok {
+		oc.AddDB(path)
+	}
 }
 
-// Scan discovers all sessions from the Claude projects directory.
+// Scan discovers the sessions of every source, in registration order.
+// Files whose size and mtime match the index are served from it and parsed
+// on demand; everything else is parsed now and written back to the index.
 func (s *Store) Scan() error {
-	entries, err := os.ReadDir(s.baseDir)
-	if err != nil {
-		return err
+	s.indexed = s.index.load()
+	s.seen = make(map[string]bool)
+
+	s.mu.Lock()
+	s.errs = make(map[string][]string)
+	s.mu.Unlock()
+
+	s.mu.Lock()
+	awaiting := s.awaiting
+	s.awaiting = nil
+	s.mu.Unlock()
+	var errs []error
+	for _, w := range awaiting {
+		errs = append(errs, w.Close())
 	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+	for _, src := range s.sources {
+		if err := src.Scan(); err != nil {
+			s.noteError(src.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name(), err))
 		}
+	}
 
-		projectDir := filepath.Join(s.baseDir, entry.Name())
+	s.index.retain(s.seen)
+	s.indexed = nil
+	s.seen = nil
 
-		// Watch this project directory for changes
-		_ = s.watcher.Add(projectDir)
+	return errors.Join(errs...)
+}
 
-		files, err := os.ReadDir(projectDir)
-		if err != nil {
-			continue
-		}
+// addIndexed registers sessions served from the index. Their events are not
+// loaded until GetSession asks for them.
+func (s *Store) addIndexed(infos []SessionInfo) []SessionChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changes []SessionChange
+	for _, info := range infos {
+		s.infos[info.ID] = info
+		changes = append(changes, SessionChange{Kind: SessionAdded, ID: info.ID, Info: info})
+	}
+	return changes
+}
 
-		for _, f := range files {
-			if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
-				continue
-			}
+// storeSession records a freshly parsed session: its metadata joins the list
+// and the full session enters the loaded cache. It returns the change if the
+// session is new or has changed, since a load can read a file's new content
+// before the watcher gets to it.
+func (s *Store) storeSession(sess *Session) []SessionChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-			path := filepath.Join(projectDir, f.Name())
-			sess, err := ParseSessionFile(path)
-			if err != nil {
-				continue
-			}
-			if len(sess.Events) == 0 {
-				continue
-			}
+	s.loaded.add(sess)
+	return s.record(nil, sess.Info)
+}
 
-			s.mu.Lock()
-			s.sessions[sess.Info.ID] = sess
-			s.mu.Unlock()
+// storeMetadata records sessions parsed in bulk. Only their metadata is kept,
+// unless they are already loaded, in which case the cached copy is refreshed.
+// It returns the sessions that are new or have changed.
+func (s *Store) storeMetadata(sessions []*Session) []SessionChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []SessionChange
+	for _, sess := range sessions {
+		if s.loaded.contains(sess.Info.ID) {
+			s.loaded.add(sess)
 		}
+		changes = s.record(changes, sess.Info)
 	}
-
-	// Scan for OpenCode databases
-	s.scanOpenCodeDBs()
-
-	return nil
+	return changes
 }
 
-// scanOpenCodeDBs discovers and parses OpenCode databases.
-func (s *Store) scanOpenCodeDBs() {
-	candidates := make(map[string]bool)
-
-	// Check explicitly provided paths
-	for _, p := range s.ocExtraDBs {
-		candidates[p] = true
+// record replaces a session's metadata and appends to changes the change it
+// amounts to, if any. The caller must hold s.mu.
+func (s *Store) record(changes []SessionChange, info SessionInfo) []SessionChange {
+	prev, known := s.infos[info.ID]
+	s.infos[info.ID] = info
+
+	switch {
+	case !known:
+		changes = append(changes, SessionChange{Kind: SessionAdded, ID: info.ID, Info: info})
+	case !prev.LastUpdate.Equal(info.LastUpdate) || prev.EventCount != info.EventCount ||
+		prev.ProjectDir != info.ProjectDir:
+		changes = append(changes, SessionChange{Kind: SessionUpdated, ID: info.ID, Info: info})
 	}
+	return changes
+}
 
-	// Check CWD of existing Claude sessions for co-located OpenCode DBs
+// GetSessions returns all sessions sorted by last update time (newest first),
+// with each subagent placed directly after the session that spawned it.
+func (s *Store) GetSessions() []SessionInfo {
 	s.mu.RLock()
-	for _, sess := range s.sessions {
-		if sess.Info.CWD != "" {
-			dbPath := filepath.Join(sess.Info.CWD, ".opencode", "opencode.db")
-			candidates[dbPath] = true
-		}
-	}
-	s.mu.RUnlock()
-
-	// Check the current working directory
-	if cwd, err := os.Getwd(); err == nil {
-		dbPath := filepath.Join(cwd, ".opencode", "opencode.db")
-		candidates[dbPath] = true
-	}
+	defer s.mu.RUnlock()
 
-	for dbPath := range candidates {
-		info, err := os.Stat(dbPath)
-		if err != nil {
-			continue
-		}
+	return s.infos.list()
+}
 
-		s.ocDBs[dbPath] = info.ModTime()
+// sessionSet is session metadata keyed by ID, across which subagents are
+// linked to their parents and usage is rolled up. The store keeps one under
+// s.mu, and a SessionList keeps one from change batches.
+type sessionSet map[string]SessionInfo
+
+// list returns the sessions as GetSessions does.
+func (set sessionSet) list() []SessionInfo {
+	subagents := set.subagents()
+	infos := make([]SessionInfo, 0, len(set))
+	for _, info := range set {
+		info = set.linkSubagent(info)
+		info.Inclusive = set.inclusiveUsage(info, subagents, nil)
+		infos = append(infos, info)
+	}
 
-		sessions, err := ParseOpenCodeDB(dbPath)
-		if err != nil {
-			continue
-		}
+	return nestSubagents(infos)
+}
 
-		s.mu.Lock()
-		for _, sess := range sessions {
-			s.sessions[sess.Info.ID] = sess
+// subagents maps each session ID to the IDs of the subagents it spawned.
+func (set sessionSet) subagents() map[string][]string {
+	children := make(map[string][]string)
+	for id, info := range set {
+		if info.ParentID != "" && info.ParentID != id {
+			children[info.ParentID] = append(children[info.ParentID], id)
 		}
-		s.mu.Unlock()
 	}
+	return children
 }
 
-// Watch starts watching for file changes and re-parses modified sessions.
-// Returns a channel that receives a signal whenever sessions are updated.
-func (s *Store) Watch() <-chan struct{} {
-	go func() {
-		// Debounce timer to avoid re-parsing on every write
-		var debounce *time.Timer
-
-		for {
-			select {
-			case event, ok := <-s.watcher.Events:
-				if !ok {
-					return
-				}
-				if !strings.HasSuffix(event.Name, ".jsonl") {
-					continue
-				}
-				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
-					continue
-				}
-
-				// Debounce: wait 500ms after last write before re-parsing
-				if debounce != nil {
-					debounce.Stop()
-				}
-				path := event.Name
-				debounce = time.AfterFunc(500*time.Millisecond, func() {
-					sess, err := ParseSessionFile(path)
-					if err != nil || len(sess.Events) == 0 {
-						return
-					}
-					s.mu.Lock()
-					s.sessions[sess.Info.ID] = sess
-					s.mu.Unlock()
-
-					// Signal update (non-blocking)
-					select {
-					case s.updates <- struct{}{}:
-					default:
-					}
-				})
-
-			case _, ok := <-s.watcher.Errors:
-				if !ok {
-					return
-				}
-			}
-		}
-	}()
-
-	// Poll OpenCode databases for changes
-	go s.watchOpenCode()
+// inclusiveUsage sums a session's own usage with that of all its descendant
+// subagents.
+func (set sessionSet) inclusiveUsage(info SessionInfo, subagents map[string][]string, seen map[string]bool) Usage {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	seen[info.ID] = true
 
-	return s.updates
+	usage := info.Own()
+	for _, id := range subagents[info.ID] {
+		if !seen[id] {
+			usage = usage.add(set.inclusiveUsage(set[id], subagents, seen))
+		}
+	}
+	return usage
 }
 
-// watchOpenCode polls tracked OpenCode databases for mtime changes.
-func (s *Store) watchOpenCode() {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		changed := false
-		for dbPath, lastMtime := range s.ocDBs {
-			info, err := os.Stat(dbPath)
-			if err != nil {
-				continue
-			}
-			if !info.ModTime().After(lastMtime) {
-				continue
-			}
+// linkSubagent fills in the Task call that spawned a subagent, which is only
+// recorded in the parent's transcript.
+func (set sessionSet) linkSubagent(info SessionInfo) SessionInfo {
+	if info.ParentID == "" || info.ParentToolID != "" {
+		return info
+	}
+	if parent, ok := set[info.ParentID]; ok {
+		info.ParentToolID = parent.AgentCalls[info.AgentID]
+	}
+	return info
+}
 
-			s.ocDBs[dbPath] = info.ModTime()
+// nestSubagents sorts sessions newest first, then moves each subagent to
+// directly after its parent, oldest subagent first. Subagents whose parent is
+// not in the list stay where they are.
+func nestSubagents(infos []SessionInfo) []SessionInfo {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastUpdate.After(infos[j].LastUpdate)
+	})
 
-			sessions, err := ParseOpenCodeDB(dbPath)
-			if err != nil {
-				continue
-			}
+	present := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		present[info.ID] = true
+	}
 
-			s.mu.Lock()
-			for _, sess := range sessions {
-				s.sessions[sess.Info.ID] = sess
-			}
-			s.mu.Unlock()
-			changed = true
+	children := make(map[string][]SessionInfo)
+	for _, info := range infos {
+		if info.ParentID != "" && present[info.ParentID] {
+			children[info.ParentID] = append(children[info.ParentID], info)
 		}
+	}
+	if len(children) == 0 {
+		return infos
+	}
 
-		if changed {
-			select {
-			case s.updates <- struct{}{}:
-			default:
-			}
+	nested := make([]SessionInfo, 0, len(infos))
+	var add func(info SessionInfo)
+	add = func(info SessionInfo) {
+		nested = append(nested, info)
+		kids := children[info.ID]
+		sort.Slice(kids, func(i, j int) bool {
+			return kids[i].StartTime.Before(kids[j].StartTime)
+		})
+		for _, kid := range kids {
+			add(kid)
+		}
+	}
+	for _, info := range infos {
+		if info.ParentID == "" || !present[info.ParentID] {
+			add(info)
 		}
 	}
+	return nested
 }
 
-// GetSessions returns all sessions sorted by last update time (newest first).
-func (s *Store) GetSessions() []SessionInfo {
+// GetSubagent returns the subagent session that a Task call in the parent
+// session spawned, identified by the Task event's AgentID.
+func (s *Store) GetSubagent(parentID, agentID string) *Session {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	infos := make([]SessionInfo, 0, len(s.sessions))
-	for _, sess := range s.sessions {
-		infos = append(infos, sess.Info)
+	var id string
+	for _, info := range s.infos {
+		if info.ParentID == parentID && info.AgentID == agentID {
+			id = info.ID
+			break
+		}
 	}
+	s.mu.RUnlock()
 
-	sort.Slice(infos, func(i, j int) bool {
-		return infos[i].LastUpdate.After(infos[j].LastUpdate)
-	})
-
-	return infos
+	if id == "" {
+		return nil
+	}
+	return s.GetSession(id)
 }
 
-// GetSession returns the full parsed session by ID.
+// GetSession returns the full parsed session by ID. Events are parsed on first
+// access and kept in a bounded cache of recently used sessions.
 func (s *Store) GetSession(id string) *Session {
+	s.mu.Lock()
+	sess, ok := s.loaded.get(id)
+	info, known := s.infos[id]
+	s.mu.Unlock()
+
+	if !ok {
+		if !known {
+			return nil
+		}
+		var err error
+		sess, err = s.loadSession(info)
+		if err != nil || len(sess.Events) == 0 {
+			sess = &Session{Info: info}
+		}
+	}
+
+	// Loaded sessions are shared, so the rolled-up usage goes on a copy.
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.sessions[id]
+	withUsage := *sess
+	withUsage.Info = s.infos.linkSubagent(withUsage.Info)
+	withUsage.Info.Inclusive = s.infos.inclusiveUsage(withUsage.Info, s.infos.subagents(), nil)
+	s.mu.RUnlock()
+	return &withUsage
+}
+
+// loadSession parses the events for a session known only by its metadata.
+func (s *Store) loadSession(info SessionInfo) (*Session, error) {
+	src := s.source(info.Source)
+	if src == nil {
+		return nil, fmt.Errorf("unknown session source %q", info.Source)
+	}
+	return src.Load(info)
 }
 
 // GetProjectInfo returns aggregated project information for a given project directory.
@@ -272,27 +362,33 @@ func (s *Store) GetProjectInfo(projectDir string) *ProjectInfo {
 	defer s.mu.RUnlock()
 
 	proj := &ProjectInfo{
-		ProjectDir: projectDir,
+		ProjectDir:       projectDir,
+		SessionsBySource: make(map[string]int),
 	}
 
 	editCounts := make(map[string]int)
+	subagents := s.infos.subagents()
+	var usage Usage
 	var encodedDir string
 
-	for _, sess := range s.sessions {
-		info := sess.Info
+	for _, info := range s.infos {
 		if info.ProjectDir != projectDir {
 			continue
 		}
 
+		info = s.infos.linkSubagent(info)
+		info.Inclusive = s.infos.inclusiveUsage(info, subagents, nil)
+
 		proj.TotalSessions++
+		proj.SessionsBySource[cmp.Or(info.Source, "claude")]++
 		proj.TotalToolCalls += info.ToolCallCount
 		proj.TotalUserPrompts += info.UserPrompts
 		proj.TotalErrors += info.Errors
-		proj.TotalInputTokens += info.InputTokens
-		proj.TotalOutputTokens += info.OutputTokens
-		proj.TotalCacheReadTokens += info.CacheReadTokens
-		proj.TotalCacheWriteTokens += info.CacheWriteTokens
-		proj.TotalCostUSD += info.CostUSD
+
+		// Subagents are already included in their parent's usage
+		if _, hasParent := s.infos[info.ParentID]; !hasParent || info.ParentID == info.ID {
+			usage = usage.add(info.Inclusive)
+		}
 
 		if proj.FirstSession.IsZero() || info.StartTime.Before(proj.FirstSession) {
 			proj.FirstSession = info.StartTime
@@ -304,8 +400,8 @@ func (s *Store) GetProjectInfo(projectDir string) *ProjectInfo {
 		if proj.ProjectName == "" {
 			proj.ProjectName = info.ProjectName
 		}
-		if encodedDir == "" && info.FilePath != "" {
-			encodedDir = filepath.Base(filepath.Dir(info.FilePath))
+		if encodedDir == "" && info.FilePath != "" && info.Source == "claude" {
+			encodedDir = projectDirName(info.FilePath)
 		}
 
 		// Count file edits
@@ -324,11 +420,15 @@ func (s *Store) GetProjectInfo(projectDir string) *ProjectInfo {
 	}
 
 	proj.EncodedDir = encodedDir
+	proj.TotalInputTokens = usage.InputTokens
+	proj.TotalOutputTokens = usage.OutputTokens
+	proj.TotalCacheReadTokens = usage.CacheReadTokens
+	proj.TotalCacheWriteTokens = usage.CacheWriteTokens
+	proj.TotalCostUSD = usage.CostUSD
+	proj.UnpricedModels = usage.UnpricedModels
 
-	// Sort sessions by LastUpdate descending
-	sort.Slice(proj.Sessions, func(i, j int) bool {
-		return proj.Sessions[i].LastUpdate.After(proj.Sessions[j].LastUpdate)
-	})
+	// Sort sessions by LastUpdate descending, subagents under their parent
+	proj.Sessions = nestSubagents(proj.Sessions)
 
 	// Build MostEditedFiles sorted desc by count
 	for fp, count := range editCounts {
@@ -356,6 +456,15 @@ func (s *Store) GetProjectInfo(projectDir string) *ProjectInfo {
 	return proj
 }
 
+// FullToolOutput returns the complete output of a tool result, reading it
+// back from the session's transcript when only a preview was kept.
+func (s *Store) FullToolOutput(info SessionInfo, e Event) (string, error) {
+	if !e.Truncated() {
+		return e.ToolOutput, nil
+	}
+	return readToolOutput(info.FilePath, e.OutputOffset, e.ToolID)
+}
+
 // GetSessionTodos reads todo items for a session from ~/.claude/todos/.
 func (s *Store) GetSessionTodos(sessionID string) []TodoItem {
 	homeDir, err := os.UserHomeDir()
@@ -394,7 +503,37 @@ func (s *Store) GetSessionTodos(sessionID string) []TodoItem {
 	return todos
 }
 
-// Close cleans up the file watcher.
+// Watch starts every source watching for changes. Changes are delivered to
+// subscribers; see Subscribe.
+func (s *Store) Watch() {
+	for _, src := range s.sources {
+		src.Watch()
+	}
+}
+
+// Close stops the sources and cleans up the session index.
 func (s *Store) Close() error {
-	return s.watcher.Close()
+	s.subMu.Lock()
+	subs := make([]*Subscription, 0, len(s.subs))
+	for sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.subMu.Unlock()
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+
+	s.mu.Lock()
+	awaiting := s.awaiting
+	s.awaiting = nil
+	s.mu.Unlock()
+	var errs []error
+	for _, w := range awaiting {
+		errs = append(errs, w.Close())
+	}
+	for _, src := range s.sources {
+		errs = append(errs, src.Close())
+	}
+	errs = append(errs, s.index.close())
+	return errors.Join(errs...)
 }
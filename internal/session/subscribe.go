@@ -0,0 +1,195 @@
+package session
+
+import (
+	"slices"
+	"sync"
+)
+
+// ChangeKind says what happened to a session.
+type ChangeKind int
+
+const (
+	SessionAdded ChangeKind = iota
+	SessionUpdated
+	SessionRemoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case SessionAdded:
+		return "added"
+	case SessionUpdated:
+		return "updated"
+	case SessionRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// SessionChange describes one session that changed.
+type SessionChange struct {
+	Kind ChangeKind
+	ID   string
+	Info SessionInfo // metadata after the change; for removals, the last known
+
+	// Events appended since the previous notification, when the store knows
+	// them. Sources that re-read a session whole (OpenCode, Codex) leave this
+	// empty.
+	Appended []Event
+}
+
+// Subscription delivers batches of session changes. Changes that happen while
+// the subscriber is busy are merged into the next batch, so a slow subscriber
+// never blocks the store and never misses a session.
+type Subscription struct {
+	C <-chan []SessionChange
+
+	store *Store
+	out   chan []SessionChange
+	ready chan struct{} // poked when pending has changes
+	done  chan struct{}
+	once  sync.Once
+
+	mu      sync.Mutex
+	pending []SessionChange
+}
+
+// Subscribe registers a new subscriber. Call Unsubscribe when done with it.
+func (s *Store) Subscribe() *Subscription {
+	sub := &Subscription{
+		store: s,
+		out:   make(chan []SessionChange),
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	sub.C = sub.out
+
+	s.subMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.subMu.Unlock()
+
+	go sub.deliver()
+	return sub
+}
+
+// Unsubscribe stops delivery and closes C. It is safe to call more than once.
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.store.subMu.Lock()
+		delete(sub.store.subs, sub)
+		sub.store.subMu.Unlock()
+		close(sub.done)
+	})
+}
+
+// deliver hands pending changes to the subscriber until it unsubscribes.
+func (sub *Subscription) deliver() {
+	defer close(sub.out)
+	for {
+		select {
+		case <-sub.ready:
+		case <-sub.done:
+			return
+		}
+
+		sub.mu.Lock()
+		batch := sub.pending
+		sub.pending = nil
+		sub.mu.Unlock()
+		if len(batch) == 0 {
+			continue
+		}
+
+		select {
+		case sub.out <- batch:
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+// add merges changes into the pending batch.
+func (sub *Subscription) add(changes []SessionChange) {
+	sub.mu.Lock()
+	for _, c := range changes {
+		sub.pending = mergeChange(sub.pending, c)
+	}
+	sub.mu.Unlock()
+
+	select {
+	case sub.ready <- struct{}{}:
+	default:
+	}
+}
+
+// mergeChange folds c into a batch that may already mention the same session.
+func mergeChange(batch []SessionChange, c SessionChange) []SessionChange {
+	for i := range batch {
+		prev := &batch[i]
+		if prev.ID != c.ID {
+			continue
+		}
+		switch {
+		case c.Kind == SessionRemoved:
+			prev.Kind, prev.Appended = SessionRemoved, nil
+		case prev.Kind == SessionRemoved:
+			// Removed, then back again
+			prev.Kind, prev.Appended = SessionUpdated, c.Appended
+		default:
+			// An addition stays an addition. Every subscriber is handed
+			// the same Appended, so it is copied rather than grown in
+			// place.
+			prev.Appended = append(slices.Clip(prev.Appended), c.Appended...)
+		}
+		prev.Info = c.Info
+		return batch
+	}
+	return append(batch, c)
+}
+
+// publish sends changes to every subscriber.
+func (s *Store) publish(changes ...SessionChange) {
+	if len(changes) == 0 {
+		return
+	}
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for sub := range s.subs {
+		sub.add(changes)
+	}
+}
+
+// SessionList is the session list kept current from change batches, so a
+// subscriber can show it without re-reading every session from the store.
+type SessionList struct {
+	infos sessionSet
+}
+
+// NewSessionList starts a list from sessions as GetSessions returns them.
+func NewSessionList(infos []SessionInfo) SessionList {
+	l := SessionList{infos: make(sessionSet, len(infos))}
+	for _, info := range infos {
+		l.infos[info.ID] = info
+	}
+	return l
+}
+
+// Apply updates the list with a batch of changes.
+func (l *SessionList) Apply(changes []SessionChange) {
+	if l.infos == nil {
+		l.infos = make(sessionSet)
+	}
+	for _, c := range changes {
+		if c.Kind == SessionRemoved {
+			delete(l.infos, c.ID)
+		} else {
+			l.infos[c.ID] = c.Info
+		}
+	}
+}
+
+// Sessions returns the list in GetSessions order, with subagents linked and
+// their usage rolled up.
+func (l SessionList) Sessions() []SessionInfo {
+	return l.infos.list()
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"testing"
+)
+
+func events(texts ...string) []Event {
+	var out []Event
+	for _, text := range texts {
+		out = append(out, Event{Type: EventUserPrompt, UserText: text})
+	}
+	return out
+}
+
+func eventTexts(events []Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.UserText)
+	}
+	return out
+}
+
+func TestMergeChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  []SessionChange
+		want     []ChangeKind
+		appended [][]string
+	}{
+		{
+			name: "added then updated stays added",
+			changes: []SessionChange{
+				{Kind: SessionAdded, ID: "a", Appended: events("one")},
+				{Kind: SessionUpdated, ID: "a", Appended: events("two")},
+			},
+			want:     []ChangeKind{SessionAdded},
+			appended: [][]string{{"one", "two"}},
+		},
+		{
+			name: "updated then removed",
+			changes: []SessionChange{
+				{Kind: SessionUpdated, ID: "a", Appended: events("one")},
+				{Kind: SessionRemoved, ID: "a"},
+			},
+			want:     []ChangeKind{SessionRemoved},
+			appended: [][]string{nil},
+		},
+		{
+			name: "removed then back",
+			changes: []SessionChange{
+				{Kind: SessionRemoved, ID: "a"},
+				{Kind: SessionAdded, ID: "a", Appended: events("one")},
+			},
+			want:     []ChangeKind{SessionUpdated},
+			appended: [][]string{{"one"}},
+		},
+		{
+			name: "different sessions",
+			changes: []SessionChange{
+				{Kind: SessionAdded, ID: "a", Appended: events("one")},
+				{Kind: SessionUpdated, ID: "b", Appended: events("two")},
+			},
+			want:     []ChangeKind{SessionAdded, SessionUpdated},
+			appended: [][]string{{"one"}, {"two"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batch []SessionChange
+			for _, c := range tt.changes {
+				batch = mergeChange(batch, c)
+			}
+			if len(batch) != len(tt.want) {
+				t.Fatalf("batch has %d changes, want %d", len(batch), len(tt.want))
+			}
+			for i, c := range batch {
+				if c.Kind != tt.want[i] {
+					t.Errorf("change %d: kind %v, want %v", i, c.Kind, tt.want[i])
+				}
+				got := eventTexts(c.Appended)
+				if len(got) != len(tt.appended[i]) {
+					t.Errorf("change %d: appended %q, want %q", i, got, tt.appended[i])
+					continue
+				}
+				for j := range got {
+					if got[j] != tt.appended[i][j] {
+						t.Errorf("change %d: appended %q, want %q", i, got, tt.appended[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMergeChangeDoesNotShareAppended(t *testing.T) {
+	// One change is handed to every subscriber. Its Appended has spare
+	// capacity, so growing it in place would let one subscriber's merge
+	// overwrite another's.
+	shared := make([]Event, 1, 4)
+	shared[0] = Event{UserText: "one"}
+	first := SessionChange{Kind: SessionUpdated, ID: "a", Appended: shared}
+
+	a := mergeChange(nil, first)
+	b := mergeChange(nil, first)
+	a = mergeChange(a, SessionChange{Kind: SessionUpdated, ID: "a", Appended: events("for a")})
+	b = mergeChange(b, SessionChange{Kind: SessionUpdated, ID: "a", Appended: events("for b")})
+
+	if got := eventTexts(a[0].Appended); len(got) != 2 || got[1] != "for a" {
+		t.Errorf("first subscriber got %q", got)
+	}
+	if got := eventTexts(b[0].Appended); len(got) != 2 || got[1] != "for b" {
+		t.Errorf("second subscriber got %q", got)
+	}
+	if len(shared) != 1 || shared[:2][1].UserText != "" {
+		t.Errorf("the published change was written into: %q", eventTexts(shared[:2]))
+	}
+}
+
+func TestSessionListApply(t *testing.T) {
+	l := NewSessionList([]SessionInfo{{ID: "a"}, {ID: "b"}})
+	l.Apply([]SessionChange{
+		{Kind: SessionRemoved, ID: "a", Info: SessionInfo{ID: "a"}},
+		{Kind: SessionUpdated, ID: "b", Info: SessionInfo{ID: "b", UserPrompts: 2}},
+		{Kind: SessionAdded, ID: "c", Info: SessionInfo{ID: "c"}},
+	})
+
+	got := make(map[string]SessionInfo)
+	for _, info := range l.Sessions() {
+		got[info.ID] = info
+	}
+	if len(got) != 2 {
+		t.Fatalf("list has %d sessions, want 2", len(got))
+	}
+	if _, ok := got["a"]; ok {
+		t.Error("removed session still listed")
+	}
+	if got["b"].UserPrompts != 2 {
+		t.Error("updated session not replaced")
+	}
+	if _, ok := got["c"]; !ok {
+		t.Error("added session not listed")
+	}
+}
@@ -0,0 +1,61 @@
+package session
+
+// linkToolCalls pairs each EventToolUse with the EventToolResult that shares
+// its ToolID. Both sides get PairIndex and ToolDuration; the call also takes
+// the result's IsError. Calls that never got a result keep PairIndex -1.
+func linkToolCalls(events []Event) {
+	pending := make(map[string]int) // ToolID → index of the unanswered call
+
+	for i := range events {
+		e := &events[i]
+		switch e.Type {
+		case EventToolUse:
+			e.PairIndex = -1
+			if e.ToolID != "" {
+				pending[e.ToolID] = i
+			}
+
+		case EventBashProgress:
+			// Progress only matters while the call is still running
+			if j, ok := pending[e.ToolID]; ok && e.ToolID != "" {
+				call := &events[j]
+				call.BashElapsedSec = e.BashElapsedSec
+				if e.BashOutput != "" {
+					call.BashOutput = e.BashOutput
+				}
+			}
+
+		case EventToolResult:
+			e.PairIndex = -1
+			j, ok := pending[e.ToolID]
+			if !ok || e.ToolID == "" {
+				continue
+			}
+			delete(pending, e.ToolID)
+
+			call := &events[j]
+			call.PairIndex, e.PairIndex = i, j
+			call.IsError = e.IsError
+
+			if !call.Timestamp.IsZero() && e.Timestamp.After(call.Timestamp) {
+				call.ToolDuration = e.Timestamp.Sub(call.Timestamp)
+				e.ToolDuration = call.ToolDuration
+			}
+		}
+	}
+}
+
+// Pair returns the event paired with the tool call or result at index i.
+func (s *Session) Pair(i int) (Event, bool) {
+	if i < 0 || i >= len(s.Events) {
+		return Event{}, false
+	}
+	e := s.Events[i]
+	if e.Type != EventToolUse && e.Type != EventToolResult {
+		return Event{}, false
+	}
+	if e.PairIndex < 0 || e.PairIndex >= len(s.Events) {
+		return Event{}, false
+	}
+	return s.Events[e.PairIndex], true
+}
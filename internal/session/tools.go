@@ -0,0 +1,188 @@
+package session
+
+import (
+	"sort"
+	"strings"
+)
+
+// ToolOp is what a tool call does, independent of the agent that made it.
+type ToolOp int
+
+const (
+	OpOther ToolOp = iota
+	OpRead
+	OpModify
+	OpCreate
+	OpDelete
+	OpExecute
+	OpNetwork
+)
+
+// ToolAction is one effect of a tool call. A call can touch several files
+// (e.g. a patch), so a call classifies to a list of actions.
+type ToolAction struct {
+	Op   ToolOp
+	Path string // file the operation applies to, empty if none
+}
+
+// ClassifyTool maps a Claude Code, OpenCode, Codex or Gemini CLI tool call to
+// the operations it performs. Tool names are matched case-insensitively, since
+// OpenCode uses lowercase names for the same tools.
+func ClassifyTool(name string, input map[string]interface{}) []ToolAction {
+	path := toolPath(input)
+
+	switch strings.ToLower(name) {
+	case "read", "view", "notebookread", "view_image", "read_file":
+		return []ToolAction{{Op: OpRead, Path: path}}
+	case "glob", "grep", "ls", "list", "list_directory", "search_file_content", "read_many_files":
+		return []ToolAction{{Op: OpRead}}
+	case "write", "write_file":
+		return []ToolAction{{Op: OpCreate, Path: path}}
+	case "edit", "multiedit", "notebookedit", "replace":
+		return []ToolAction{{Op: OpModify, Path: path}}
+	case "patch", "apply_patch":
+		return patchActions(input)
+	case "bash", "shell", "shell_command", "exec_command", "local_shell", "run_shell_command":
+		return []ToolAction{{Op: OpExecute}}
+	case "webfetch", "websearch", "web_search", "fetch", "sourcegraph", "web_fetch", "google_web_search":
+		return []ToolAction{{Op: OpNetwork}}
+	}
+	return []ToolAction{{Op: OpOther}}
+}
+
+// toolPath returns the file a tool call operates on. Claude Code uses
+// file_path (notebook_path for notebooks), OpenCode uses filePath, and Gemini
+// CLI's read_file uses absolute_path.
+func toolPath(input map[string]interface{}) string {
+	for _, key := range []string{"file_path", "filePath", "notebook_path", "absolute_path", "path"} {
+		if fp, ok := input[key].(string); ok && fp != "" {
+			return fp
+		}
+	}
+	return ""
+}
+
+// PatchText returns the patch a patch or apply_patch call applies, under
+// whichever key the agent passes it.
+func PatchText(input map[string]interface{}) string {
+	for _, key := range []string{"patch_text", "patchText", "patch", "input"} {
+		if s, ok := input[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// patchActions lists the files a patch adds, updates or deletes. It reads the
+// "*** Add File:" envelope format used by OpenCode and Codex, falling back to
+// unified diff headers.
+func patchActions(input map[string]interface{}) []ToolAction {
+	text := PatchText(input)
+
+	var actions []ToolAction
+	seen := make(map[string]bool)
+	add := func(op ToolOp, path string) {
+		path = strings.TrimSpace(path)
+		if path == "" || path == "/dev/null" || seen[path] {
+			return
+		}
+		seen[path] = true
+		actions = append(actions, ToolAction{Op: op, Path: path})
+	}
+
+	var oldPath string
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "*** Add File: "):
+			add(OpCreate, strings.TrimPrefix(line, "*** Add File: "))
+		case strings.HasPrefix(line, "*** Update File: "):
+			add(OpModify, strings.TrimPrefix(line, "*** Update File: "))
+		case strings.HasPrefix(line, "*** Delete File: "):
+			add(OpDelete, strings.TrimPrefix(line, "*** Delete File: "))
+		case strings.HasPrefix(line, "--- "):
+			oldPath = diffPath(strings.TrimPrefix(line, "--- "))
+		case strings.HasPrefix(line, "+++ "):
+			newPath := diffPath(strings.TrimPrefix(line, "+++ "))
+			switch {
+			case oldPath == "/dev/null":
+				add(OpCreate, newPath)
+			case newPath == "/dev/null":
+				add(OpDelete, oldPath)
+			default:
+				add(OpModify, newPath)
+			}
+		}
+	}
+
+	if len(actions) == 0 {
+		return []ToolAction{{Op: OpModify, Path: toolPath(input)}}
+	}
+	return actions
+}
+
+// diffPath strips the a/ or b/ prefix and any timestamp from a diff header.
+func diffPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// fileStats accumulates a session's file lists and counters from its tool
+// calls.
+type fileStats struct {
+	read, modified, created, deleted map[string]bool
+	executed                         int
+}
+
+func newFileStats() *fileStats {
+	return &fileStats{
+		read:     make(map[string]bool),
+		modified: make(map[string]bool),
+		created:  make(map[string]bool),
+		deleted:  make(map[string]bool),
+	}
+}
+
+// add records the operations of a tool call event.
+func (f *fileStats) add(e Event) {
+	for _, a := range ClassifyTool(e.ToolName, e.ToolInput) {
+		if a.Op == OpExecute {
+			f.executed++
+		}
+		if a.Path == "" {
+			continue
+		}
+		switch a.Op {
+		case OpRead:
+			f.read[a.Path] = true
+		case OpModify:
+			f.modified[a.Path] = true
+		case OpCreate:
+			f.created[a.Path] = true
+		case OpDelete:
+			f.deleted[a.Path] = true
+		}
+	}
+}
+
+// apply fills in the file lists and counters of info.
+func (f *fileStats) apply(info *SessionInfo) {
+	info.FilesRead = sortedKeys(f.read)
+	info.FilesWritten = sortedKeys(f.modified)
+	info.FilesCreated = sortedKeys(f.created)
+	info.FilesDeleted = sortedKeys(f.deleted)
+	info.BashCommands = f.executed
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
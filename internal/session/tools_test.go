@@ -0,0 +1,72 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatchActions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  []ToolAction
+	}{
+		{
+			name: "envelope",
+			input: map[string]interface{}{"patch_text": "*** Begin Patch\n" +
+				"*** Add File: new.go\n+package main\n" +
+				"*** Update File: main.go\n@@\n-a\n+b\n" +
+				"*** Delete File: old.go\n" +
+				"*** End Patch"},
+			want: []ToolAction{{OpCreate, "new.go"}, {OpModify, "main.go"}, {OpDelete, "old.go"}},
+		},
+		{
+			name: "unified diff",
+			input: map[string]interface{}{"patch": "--- a/main.go\t2025-01-01\n+++ b/main.go\t2025-01-01\n@@ -1 +1 @@\n-a\n+b\n" +
+				"--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+x\n" +
+				"--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n"},
+			want: []ToolAction{{OpModify, "main.go"}, {OpCreate, "new.go"}, {OpDelete, "old.go"}},
+		},
+		{
+			name:  "repeated file",
+			input: map[string]interface{}{"patchText": "*** Update File: a.go\n*** Update File: a.go\n"},
+			want:  []ToolAction{{OpModify, "a.go"}},
+		},
+		{
+			name:  "no headers",
+			input: map[string]interface{}{"input": "something", "path": "x.go"},
+			want:  []ToolAction{{OpModify, "x.go"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := patchActions(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patchActions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyTool(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  ToolAction
+	}{
+		{"MultiEdit", map[string]interface{}{"file_path": "/p/a.go"}, ToolAction{OpModify, "/p/a.go"}},
+		{"NotebookEdit", map[string]interface{}{"notebook_path": "/p/n.ipynb"}, ToolAction{OpModify, "/p/n.ipynb"}},
+		{"edit", map[string]interface{}{"filePath": "/p/b.go"}, ToolAction{OpModify, "/p/b.go"}},
+		{"write", map[string]interface{}{"filePath": "/p/c.go"}, ToolAction{OpCreate, "/p/c.go"}},
+		{"read_file", map[string]interface{}{"absolute_path": "/p/d.go"}, ToolAction{OpRead, "/p/d.go"}},
+		{"Bash", map[string]interface{}{"command": "ls"}, ToolAction{Op: OpExecute}},
+		{"TodoWrite", nil, ToolAction{Op: OpOther}},
+	}
+
+	for _, tt := range tests {
+		got := ClassifyTool(tt.name, tt.input)
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("ClassifyTool(%q) = %v, want [%v]", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package session
+
+import (
+	"sort"
+	"time"
+)
+
+// TreeNode is a user or assistant message in the conversation tree.
+type TreeNode struct {
+	UUID      string
+	Parent    string // nearest user/assistant ancestor, empty for roots
+	Children  []string
+	Timestamp time.Time
+}
+
+// ConversationTree is the shape of a session's conversation, built from each
+// entry's parentUuid. Editing an earlier prompt or rewinding makes Claude Code
+// continue from an earlier message, leaving the old continuation as an
+// abandoned branch.
+type ConversationTree struct {
+	Nodes      map[string]*TreeNode // keyed by message UUID
+	Roots      []string
+	ActiveLeaf string // the most recently written message — the branch in use
+}
+
+// Branch is the path from a root of the conversation tree to one of its leaves.
+type Branch struct {
+	Leaf    string
+	Fork    string // last message shared with the active branch, empty if none
+	Active  bool
+	Updated time.Time // timestamp of the leaf message
+}
+
+// treeEntry is what the parser remembers about every entry to build the tree.
+type treeEntry struct {
+	parent    string
+	message   bool // user or assistant entry
+	timestamp time.Time
+}
+
+// buildTree assembles the conversation tree from the entries seen so far,
+// given in file order. Non-message entries (progress, system) are skipped
+// over, so each message's parent is its nearest message ancestor.
+func buildTree(entries map[string]treeEntry, order []string) *ConversationTree {
+	t := &ConversationTree{Nodes: make(map[string]*TreeNode)}
+
+	for _, uuid := range order {
+		if e := entries[uuid]; e.message {
+			t.Nodes[uuid] = &TreeNode{UUID: uuid, Timestamp: e.timestamp}
+			t.ActiveLeaf = uuid
+		}
+	}
+
+	for _, uuid := range order {
+		node, ok := t.Nodes[uuid]
+		if !ok {
+			continue
+		}
+		node.Parent = messageAncestor(entries, entries[uuid].parent)
+		if parent, ok := t.Nodes[node.Parent]; ok {
+			parent.Children = append(parent.Children, uuid)
+		} else {
+			node.Parent = ""
+			t.Roots = append(t.Roots, uuid)
+		}
+	}
+
+	return t
+}
+
+// messageAncestor walks up from uuid to the first user/assistant entry.
+func messageAncestor(entries map[string]treeEntry, uuid string) string {
+	for steps := 0; uuid != "" && steps <= len(entries); steps++ {
+		e, ok := entries[uuid]
+		if !ok {
+			return ""
+		}
+		if e.message {
+			return uuid
+		}
+		uuid = e.parent
+	}
+	return ""
+}
+
+// Path returns the set of messages from a root down to leaf.
+func (t *ConversationTree) Path(leaf string) map[string]bool {
+	path := make(map[string]bool)
+	for uuid := leaf; uuid != "" && !path[uuid]; {
+		node, ok := t.Nodes[uuid]
+		if !ok {
+			break
+		}
+		path[uuid] = true
+		uuid = node.Parent
+	}
+	return path
+}
+
+// Branches lists every path through the tree: the active branch first, then
+// abandoned branches, most recently updated first.
+func (t *ConversationTree) Branches() []Branch {
+	if t == nil || t.ActiveLeaf == "" {
+		return nil
+	}
+
+	active := t.Path(t.ActiveLeaf)
+	branches := []Branch{{
+		Leaf:    t.ActiveLeaf,
+		Active:  true,
+		Updated: t.Nodes[t.ActiveLeaf].Timestamp,
+	}}
+
+	var abandoned []Branch
+	for uuid, node := range t.Nodes {
+		if len(node.Children) > 0 || uuid == t.ActiveLeaf {
+			continue
+		}
+		b := Branch{Leaf: uuid, Updated: node.Timestamp}
+		for up, steps := node.Parent, 0; up != "" && steps < len(t.Nodes); up, steps = t.Nodes[up].Parent, steps+1 {
+			if active[up] {
+				b.Fork = up
+				break
+			}
+		}
+		abandoned = append(abandoned, b)
+	}
+	sort.Slice(abandoned, func(i, j int) bool {
+		return abandoned[i].Updated.After(abandoned[j].Updated)
+	})
+
+	return append(branches, abandoned...)
+}
+
+// IsFork reports whether a message has more than one continuation.
+func (t *ConversationTree) IsFork(uuid string) bool {
+	if t == nil {
+		return false
+	}
+	node, ok := t.Nodes[uuid]
+	return ok && len(node.Children) > 1
+}
+
+// Timeline returns the indices into s.Events of the events on the branch
+// ending at leaf, in order. An empty leaf selects the active branch. Events
+// that are not part of the tree (e.g. OpenCode sessions) are always included.
+func (s *Session) Timeline(leaf string) []int {
+	var path map[string]bool
+	if s.Tree != nil {
+		if leaf == "" {
+			leaf = s.Tree.ActiveLeaf
+		}
+		path = s.Tree.Path(leaf)
+	}
+
+	timeline := make([]int, 0, len(s.Events))
+	for i, e := range s.Events {
+		if path == nil || e.Node == "" || path[e.Node] {
+			timeline = append(timeline, i)
+		}
+	}
+	return timeline
+}
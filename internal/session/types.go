@@ -2,6 +2,7 @@ package session
 
 import (
 	"encoding/json"
+	"slices"
 	"time"
 )
 
@@ -19,7 +20,8 @@ type SessionInfo struct {
 	OutputTokens     int
 	CacheReadTokens  int
 	CacheWriteTokens int
-	CostUSD          float64
+	CostUSD          float64  // API-equivalent cost, priced per message by model
+	UnpricedModels   []string // models with no known price, excluded from CostUSD
 
 	// Activity summary
 	EventCount    int
@@ -28,19 +30,85 @@ type SessionInfo struct {
 	FilesRead     []string // unique file paths read
 	FilesWritten  []string // unique file paths written/edited
 	FilesCreated  []string // unique file paths created via Write
+	FilesDeleted  []string // unique file paths deleted via a patch
 	BashCommands  int
 	Errors        int
 
-	IsAgent bool   // agent-* files are subagent sessions
+	Diagnostics []Diagnostic // problems found while parsing the session's file
+
+	IsAgent bool // agent-* files are subagent sessions
 	Model   string
 	CWD     string
-	Source  string // "claude" or "opencode"
+	Source  string // Name of the Source that read the session: "claude", "opencode", "codex", "gemini" or "aider"
+
+	// Entry metadata, in the order first seen
+	GitBranches []string // git branches the session ran on
+	Versions    []string // CLI versions that wrote the transcript
+	IsSidechain bool     // entries are marked isSidechain (subagent conversations)
+
+	// Subagent linkage
+	AgentID      string            // for subagents: the agent's ID
+	ParentID     string            // for subagents: the session that spawned it
+	ParentToolID string            // for subagents: ToolID of the Task call that spawned it
+	AgentCalls   map[string]string // for parents: agent ID → ToolID of the spawning Task call
+
+	// Usage of this session plus every subagent it spawned, directly or
+	// not. Filled in by the Store; the fields above are the session's own.
+	Inclusive Usage `json:"-"`
+}
+
+// Usage is token usage and its API-equivalent cost.
+type Usage struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	CostUSD          float64
+	UnpricedModels   []string
+}
+
+// Tokens returns the total of all token kinds.
+func (u Usage) Tokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+}
+
+// add returns the sum of two usages.
+func (u Usage) add(v Usage) Usage {
+	sum := Usage{
+		InputTokens:      u.InputTokens + v.InputTokens,
+		OutputTokens:     u.OutputTokens + v.OutputTokens,
+		CacheReadTokens:  u.CacheReadTokens + v.CacheReadTokens,
+		CacheWriteTokens: u.CacheWriteTokens + v.CacheWriteTokens,
+		CostUSD:          u.CostUSD + v.CostUSD,
+		UnpricedModels:   u.UnpricedModels,
+	}
+	for _, model := range v.UnpricedModels {
+		if !slices.Contains(sum.UnpricedModels, model) {
+			sum.UnpricedModels = append(slices.Clip(sum.UnpricedModels), model)
+		}
+	}
+	slices.Sort(sum.UnpricedModels)
+	return sum
+}
+
+// Own returns the session's usage excluding its subagents.
+func (i SessionInfo) Own() Usage {
+	return Usage{
+		InputTokens:      i.InputTokens,
+		OutputTokens:     i.OutputTokens,
+		CacheReadTokens:  i.CacheReadTokens,
+		CacheWriteTokens: i.CacheWriteTokens,
+		CostUSD:          i.CostUSD,
+		UnpricedModels:   i.UnpricedModels,
+	}
 }
 
 // Session is a fully parsed session with all events.
 type Session struct {
-	Info   SessionInfo
-	Events []Event
+	Info        SessionInfo
+	Events      []Event // every event from every branch, in file order
+	Tree        *ConversationTree
+	Checkpoints []Checkpoint // file backups taken before prompts, in file order
 }
 
 // EventType classifies what kind of event occurred.
@@ -65,6 +133,7 @@ type Event struct {
 	Type      EventType
 	Timestamp time.Time
 	UUID      string
+	Node      string // UUID of the message this event belongs to in Session.Tree
 
 	// EventUserPrompt
 	UserText string
@@ -82,13 +151,24 @@ type Event struct {
 
 	// EventToolResult
 	ToolOutput string
-	IsError    bool
+	IsError    bool // also set on an EventToolUse whose result was an error
+
+	// A tool output over maxToolOutput is kept as a preview: ToolOutput holds
+	// its start, OutputSize its full length in bytes, and OutputOffset the
+	// transcript offset of the line it can be read back from with
+	// Store.FullToolOutput.
+	OutputSize   int
+	OutputOffset int64
+
+	// EventToolUse and EventToolResult are paired by ToolID
+	PairIndex    int           // index of the matching call/result in Session.Events, -1 if none
+	ToolDuration time.Duration // wall-clock time from the call to its result
 
 	// EventCompaction
 	CompactPreTokens int
 	CompactTrigger   string
 
-	// EventAgentProgress
+	// EventAgentProgress, and EventToolUse for a Task call that spawned a subagent
 	AgentID          string
 	AgentDescription string // from "prompt" or task description
 
@@ -96,8 +176,10 @@ type Event struct {
 	HookEvent string // "PostToolUse", etc.
 	HookName  string // "PostToolUse:Read", etc.
 
-	// EventBashProgress
+	// EventBashProgress, whose ToolID is the running Bash call's. A Bash
+	// EventToolUse carries its latest progress until the result arrives.
 	BashElapsedSec int
+	BashOutput     string // output so far
 
 	// EventTurnDuration
 	TurnDurationMs int
@@ -107,19 +189,26 @@ type Event struct {
 	OutputTokens int
 }
 
+// Truncated reports whether ToolOutput is a preview of a larger output.
+func (e Event) Truncated() bool {
+	return e.OutputSize > len(e.ToolOutput)
+}
+
 // rawEntry represents a single line in the JSONL transcript.
 type rawEntry struct {
-	Type       string      `json:"type"`
-	Subtype    string      `json:"subtype"`
-	UUID       string      `json:"uuid"`
-	ParentUUID *string     `json:"parentUuid"`
-	SessionID  string      `json:"sessionId"`
-	CWD        string      `json:"cwd"`
-	Timestamp  string      `json:"timestamp"`
-	Version    string      `json:"version"`
-	GitBranch  string      `json:"gitBranch"`
-	Message    *rawMessage `json:"message"`
-	Content    string      `json:"content"`
+	Type              string      `json:"type"`
+	Subtype           string      `json:"subtype"`
+	UUID              string      `json:"uuid"`
+	ParentUUID        *string     `json:"parentUuid"`
+	LogicalParentUUID *string     `json:"logicalParentUuid"` // set on compaction boundaries, which have no parent
+	SessionID         string      `json:"sessionId"`
+	CWD               string      `json:"cwd"`
+	Timestamp         string      `json:"timestamp"`
+	Version           string      `json:"version"`
+	GitBranch         string      `json:"gitBranch"`
+	IsSidechain       bool        `json:"isSidechain"`
+	Message           *rawMessage `json:"message"`
+	Content           string      `json:"content"`
 
 	// Compaction metadata
 	CompactMetadata  *rawCompactMetadata `json:"compactMetadata"`
@@ -128,6 +217,28 @@ type rawEntry struct {
 	// Progress events and system metadata
 	Data       json.RawMessage `json:"data"`
 	DurationMs int             `json:"durationMs"`
+
+	// Subagent linkage
+	AgentID         string          `json:"agentId"`
+	ParentToolUseID string          `json:"parentToolUseID"`
+	ToolUseResult   json.RawMessage `json:"toolUseResult"` // object for Task results, carries agentId
+
+	// File history snapshots
+	MessageID        string       `json:"messageId"`
+	Snapshot         *rawSnapshot `json:"snapshot"`
+	IsSnapshotUpdate bool         `json:"isSnapshotUpdate"`
+}
+
+type rawSnapshot struct {
+	MessageID          string                   `json:"messageId"`
+	TrackedFileBackups map[string]rawFileBackup `json:"trackedFileBackups"`
+	Timestamp          string                   `json:"timestamp"`
+}
+
+type rawFileBackup struct {
+	BackupFileName *string `json:"backupFileName"` // null if the file did not exist yet
+	Version        int     `json:"version"`
+	BackupTime     string  `json:"backupTime"`
 }
 
 type rawCompactMetadata struct {
@@ -159,7 +270,8 @@ type rawProgressData struct {
 	HookEvent       string `json:"hookEvent"`
 	HookName        string `json:"hookName"`
 	ElapsedTimeSec  int    `json:"elapsedTimeSeconds"`
-	Output          string `json:"output"`
+	Output          string `json:"output"`     // recent output
+	FullOutput      string `json:"fullOutput"` // all output so far, when recorded
 }
 
 // ProjectInfo holds project-level aggregated information.
@@ -172,10 +284,12 @@ type ProjectInfo struct {
 
 	// Aggregate stats
 	TotalSessions, TotalToolCalls, TotalUserPrompts, TotalErrors int
+	SessionsBySource                                             map[string]int // session count per Source name
 	TotalInputTokens, TotalOutputTokens                          int
 	TotalCacheReadTokens, TotalCacheWriteTokens                  int
-	TotalCostUSD                                                  float64
-	FirstSession, LastSession                                     time.Time
+	TotalCostUSD                                                 float64
+	UnpricedModels                                               []string // models excluded from TotalCostUSD
+	FirstSession, LastSession                                    time.Time
 
 	MostEditedFiles []FileEditCount // sorted desc by count
 	Sessions        []SessionInfo   // sorted desc by LastUpdate
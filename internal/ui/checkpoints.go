@@ -0,0 +1,148 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fooxytv/verbose/internal/session"
+)
+
+// renderCheckpointList renders the file checkpoints of a session, one per
+// prompt that had files backed up before it.
+func renderCheckpointList(sess *session.Session, cursor int, width, height int) string {
+	info := sess.Info
+	var lines []string
+
+	lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s > %s  Checkpoints (%d)", info.ProjectName, shortID(info.ID), len(sess.Checkpoints))))
+	lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
+
+	if len(sess.Checkpoints) == 0 {
+		lines = append(lines, "")
+		lines = append(lines, dimStyle.Render("  No file checkpoints were recorded for this session."))
+		return strings.Join(lines, "\n")
+	}
+
+	for i, cp := range sess.Checkpoints {
+		files := fmt.Sprintf("%d files", len(cp.Files))
+		if len(cp.Files) == 1 {
+			files = "1 file"
+		}
+		prompt := firstLine(cp.Prompt)
+		if prompt == "" {
+			prompt = "(prompt not in transcript)"
+		}
+		line := fmt.Sprintf("%s  %8s  %s", cp.Timestamp.Format("01-02 15:04:05"), files, truncate(prompt, width-36))
+		if i == cursor {
+			lines = append(lines, selectedStyle.Render("▸ "+line))
+		} else {
+			lines = append(lines, normalStyle.Render("  "+line))
+		}
+	}
+
+	// Keep the cursor in view
+	visibleHeight := max(1, height-3)
+	start := 0
+	if cursor+2 >= visibleHeight {
+		start = cursor + 2 - visibleHeight + 1
+	}
+	end := min(len(lines), start+visibleHeight)
+	return strings.Join(lines[start:end], "\n")
+}
+
+// renderCheckpoint renders the files one checkpoint captured, each compared
+// with the file on disk, and the diff of the selected file. The comparisons
+// are worked out when the checkpoint is opened, since each reads two files.
+func renderCheckpoint(sess *session.Session, cp session.Checkpoint, compared []session.Comparison, outside []string, fileCursor, scroll int, confirm bool, status string, width, height int) string {
+	var lines []string
+
+	lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s > %s  Checkpoint %s", sess.Info.ProjectName, shortID(sess.Info.ID), cp.Timestamp.Format("15:04:05"))))
+	if cp.Prompt != "" {
+		lines = append(lines, "  "+mutedStyle.Render("before: ")+dimStyle.Render(truncate(firstLine(cp.Prompt), width-14)))
+	}
+	lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
+
+	if confirm {
+		lines = append(lines, "  "+toolErrorStyle.Render("Overwrite the files on disk with this checkpoint's backups?")+
+			mutedStyle.Render("  y to restore, any other key to cancel"))
+		if len(outside) > 0 {
+			lines = append(lines, "  "+systemStyle.Render("These files are outside the session's directories and will not be restored:"))
+			for _, path := range outside {
+				lines = append(lines, "    "+toolErrorStyle.Render(path))
+			}
+		}
+	} else if status != "" {
+		lines = append(lines, "  "+systemStyle.Render(status))
+	}
+	lines = append(lines, "")
+
+	// Captured files and how they compare with disk
+	lines = append(lines, sectionHeader(fmt.Sprintf("Captured Files (%d)", len(cp.Files))))
+	var selected *session.Comparison
+	for i, f := range cp.Files {
+		if i >= len(compared) {
+			break
+		}
+		st := compared[i].Status
+		stStyle := mutedStyle
+		switch st {
+		case session.FileModified, session.FileAdded:
+			stStyle = toolUseStyle
+		case session.FileMissing, session.FileBackupMissing:
+			stStyle = toolErrorStyle
+		}
+		label := stStyle.Render(st.String())
+		if slices.Contains(outside, f.Path) {
+			label += toolErrorStyle.Render(" · outside project")
+		}
+		line := fmt.Sprintf("%-60s  %s", truncate(relativePath(f.Path, sess.Info.CWD), 60), label)
+		if i == fileCursor {
+			selected = &compared[i]
+			lines = append(lines, selectedStyle.Render("  ▸ ")+line)
+		} else {
+			lines = append(lines, "    "+line)
+		}
+	}
+	lines = append(lines, "")
+
+	// Diff of the selected file: backup (removed) → disk now (added)
+	if selected != nil {
+		lines = append(lines, sectionHeader("Checkpoint → Disk Now"))
+		switch selected.Status {
+		case session.FileUnchanged:
+			lines = append(lines, "  "+mutedStyle.Render("The file on disk matches the checkpoint."))
+		case session.FileBackupMissing:
+			lines = append(lines, "  "+toolErrorStyle.Render("The backup for this file is no longer in ~/.claude/file-history."))
+		default:
+			lines = append(lines, renderEditDiff(map[string]interface{}{
+				"old_string": selected.Removed,
+				"new_string": selected.Added,
+			}, width)...)
+		}
+	}
+
+	// Apply scroll
+	visibleHeight := height - 3
+	if visibleHeight < 1 {
+		visibleHeight = 1
+	}
+	if scroll > len(lines)-visibleHeight {
+		scroll = max(0, len(lines)-visibleHeight)
+	}
+	end := scroll + visibleHeight
+	if end > len(lines) {
+		end = len(lines)
+	}
+	visible := lines[scroll:end]
+
+	// Scroll indicator
+	if len(lines) > visibleHeight {
+		pct := float64(scroll+visibleHeight) / float64(len(lines)) * 100
+		if pct > 100 {
+			pct = 100
+		}
+		visible = append(visible, mutedStyle.Render(fmt.Sprintf("  [%.0f%%]", pct)))
+	}
+
+	return strings.Join(visible, "\n")
+}
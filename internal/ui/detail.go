@@ -4,24 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fooxytv/verbose/internal/session"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
-// renderSessionDetail renders the timeline view for a single session.
-func renderSessionDetail(sess *session.Session, cursor int, width, height int) string {
+// renderSessionDetail renders the timeline view for a single session. Only the
+// events in timeline (indices into sess.Events) are shown — those on the
+// selected conversation branch.
+func renderSessionDetail(sess *session.Session, timeline []int, branches []session.Branch, branch int, cursor int, width, height int) string {
 	var b strings.Builder
 
 	info := sess.Info
-	totalTokens := info.InputTokens + info.OutputTokens + info.CacheReadTokens + info.CacheWriteTokens
+	totalTokens := info.Inclusive.Tokens()
 
 	header := headerStyle.Render(fmt.Sprintf(" %s > %s  Timeline", info.ProjectName, shortID(info.ID)))
 	stats := mutedStyle.Render(fmt.Sprintf(
 		" %s | %s | tools: %d | events: %d",
 		tokenStyle.Render(formatTokens(totalTokens)),
-		costStyle.Render(fmt.Sprintf("$%.4f", info.CostUSD)),
+		costStyle.Render(formatCost(info.Inclusive.CostUSD, info.Inclusive.UnpricedModels)),
 		info.ToolCallCount,
 		info.EventCount,
 	))
@@ -37,29 +40,55 @@ func renderSessionDetail(sess *session.Session, cursor int, width, height int) s
 	if len(info.FilesCreated) > 0 {
 		fileParts = append(fileParts, userStyle.Render(fmt.Sprintf("+ %d created", len(info.FilesCreated))))
 	}
+	if len(info.FilesDeleted) > 0 {
+		fileParts = append(fileParts, toolErrorStyle.Render(fmt.Sprintf("− %d deleted", len(info.FilesDeleted))))
+	}
 	if len(info.FilesRead) > 0 {
 		fileParts = append(fileParts, dimStyle.Render(fmt.Sprintf("◉ %d read", len(info.FilesRead))))
 	}
+	headerLines := 3
 	if len(fileParts) > 0 {
 		b.WriteString("  ")
 		b.WriteString(strings.Join(fileParts, mutedStyle.Render("  |  ")))
 		b.WriteString("\n")
+		headerLines++
+	}
+
+	// Conversation branches from edited prompts or rewinds
+	var activePath map[string]bool
+	if len(branches) > 1 && branch < len(branches) {
+		br := branches[branch]
+		label := fmt.Sprintf("⑂ branch %d/%d", branch+1, len(branches))
+		if br.Active {
+			b.WriteString("  " + agentStyle.Render(label) + mutedStyle.Render(" · active · b to browse abandoned branches"))
+		} else {
+			activePath = sess.Tree.Path(sess.Tree.ActiveLeaf)
+			forked := "no shared history"
+			if fork, ok := sess.Tree.Nodes[br.Fork]; ok {
+				forked = "forked at " + fork.Timestamp.Format("15:04:05")
+			}
+			b.WriteString("  " + agentStyle.Render(label) + systemStyle.Render(" · abandoned · ") + mutedStyle.Render(forked))
+		}
+		b.WriteString("\n")
+		headerLines++
 	}
 
 	b.WriteString(mutedStyle.Render(strings.Repeat("─", min(width, 100))))
 	b.WriteString("\n")
 
-	events := sess.Events
+	// Prompts that Claude Code backed files up before
+	checkpointed := make(map[string]bool, len(sess.Checkpoints))
+	for _, cp := range sess.Checkpoints {
+		checkpointed[cp.MessageID] = true
+	}
+
+	events := timeline
 	if len(events) == 0 {
 		b.WriteString(dimStyle.Render("  No events in this session."))
 		return b.String()
 	}
 
 	// Calculate visible range
-	headerLines := 3
-	if len(fileParts) > 0 {
-		headerLines = 4
-	}
 	listHeight := height - headerLines - 2
 	if listHeight < 1 {
 		listHeight = 1
@@ -74,7 +103,7 @@ func renderSessionDetail(sess *session.Session, cursor int, width, height int) s
 	}
 
 	for i := start; i < end; i++ {
-		e := events[i]
+		e := sess.Events[events[i]]
 		selected := i == cursor
 
 		if selected {
@@ -83,8 +112,19 @@ func renderSessionDetail(sess *session.Session, cursor int, width, height int) s
 			row := selBg.Render("▸ "+line) + selBg.Render(strings.Repeat(" ", max(0, width-visibleLen(line)-2)))
 			b.WriteString(row)
 		} else {
+			// Gutter: ⑂ after the last event before a fork, ⟲ on a prompt with a
+			// file checkpoint, ┊ on an abandoned branch
+			gutter := "  "
+			lastOfNode := i+1 >= len(events) || sess.Events[events[i+1]].Node != e.Node
+			if lastOfNode && sess.Tree.IsFork(e.Node) {
+				gutter = agentStyle.Render("⑂") + " "
+			} else if e.Type == session.EventUserPrompt && checkpointed[e.UUID] {
+				gutter = systemStyle.Render("⟲") + " "
+			} else if activePath != nil && e.Node != "" && !activePath[e.Node] {
+				gutter = systemStyle.Render("┊") + " "
+			}
 			line := formatEventLine(e, width-4)
-			b.WriteString("  " + line)
+			b.WriteString(gutter + line)
 		}
 		b.WriteString("\n")
 	}
@@ -116,24 +156,12 @@ func formatEventLine(e session.Event, maxWidth int) string {
 
 	case session.EventToolUse:
 		name := fmt.Sprintf("▷ %-6s", e.ToolName)
+		outcome, outcomeStyle := toolOutcome(e)
 		summary := formatToolSummary(e.ToolName, e.ToolInput)
-		summary = truncate(summary, maxWidth-25)
+		summary = truncate(summary, maxWidth-26-len([]rune(outcome)))
 		// Colour-code by operation type
-		nameStyle := toolUseStyle
-		summaryStyle := dimStyle
-		switch e.ToolName {
-		case "Edit":
-			nameStyle = lipgloss.NewStyle().Foreground(colorYellow).Bold(true)
-			summaryStyle = lipgloss.NewStyle().Foreground(colorYellow)
-		case "Write":
-			nameStyle = lipgloss.NewStyle().Foreground(colorGreen).Bold(true)
-			summaryStyle = lipgloss.NewStyle().Foreground(colorGreen)
-		case "Read":
-			nameStyle = lipgloss.NewStyle().Foreground(colorCyan).Bold(true)
-		case "Bash":
-			nameStyle = lipgloss.NewStyle().Foreground(colorOrange).Bold(true)
-		}
-		return fmt.Sprintf("%s  %s  %s", tsStr, nameStyle.Render(name), summaryStyle.Render(summary))
+		nameStyle, summaryStyle := toolStyles(e)
+		return fmt.Sprintf("%s  %s  %s %s", tsStr, nameStyle.Render(name), summaryStyle.Render(summary), outcomeStyle.Render(outcome))
 
 	case session.EventToolResult:
 		if e.IsError {
@@ -141,7 +169,7 @@ func formatEventLine(e session.Event, maxWidth int) string {
 			return fmt.Sprintf("%s  %s  %s", tsStr, toolErrorStyle.Render("✗ error "), dimStyle.Render(text))
 		}
 		text := truncate(firstLine(e.ToolOutput), maxWidth-25)
-		outputLen := len(e.ToolOutput)
+		outputLen := max(len(e.ToolOutput), e.OutputSize)
 		sizeHint := ""
 		if outputLen > 1000 {
 			sizeHint = mutedStyle.Render(fmt.Sprintf(" (%s)", formatBytes(outputLen)))
@@ -174,7 +202,8 @@ func formatEventLine(e session.Event, maxWidth int) string {
 		return fmt.Sprintf("%s  %s  %s", tsStr, dimStyle.Render("⚡ hook  "), mutedStyle.Render(name))
 
 	case session.EventBashProgress:
-		return fmt.Sprintf("%s  %s  %s", tsStr, dimStyle.Render("… bash  "), mutedStyle.Render(fmt.Sprintf("%ds", e.BashElapsedSec)))
+		text := truncate(lastLine(e.BashOutput), maxWidth-30)
+		return fmt.Sprintf("%s  %s  %s %s", tsStr, dimStyle.Render("… bash  "), mutedStyle.Render(fmt.Sprintf("%ds", e.BashElapsedSec)), dimStyle.Render(text))
 
 	case session.EventTurnDuration:
 		return fmt.Sprintf("%s  %s  %s", tsStr, dimStyle.Render("⏱ turn  "), mutedStyle.Render(fmt.Sprintf("%dms", e.TurnDurationMs)))
@@ -210,23 +239,11 @@ func formatEventLineSelected(e session.Event, maxWidth int) string {
 
 	case session.EventToolUse:
 		name := fmt.Sprintf("▷ %-6s", e.ToolName)
+		outcome, outcomeStyle := toolOutcome(e)
 		summary := formatToolSummary(e.ToolName, e.ToolInput)
-		summary = truncate(summary, maxWidth-25)
-		nameStyle := toolUseStyle
-		summaryStyle := dimStyle
-		switch e.ToolName {
-		case "Edit":
-			nameStyle = lipgloss.NewStyle().Foreground(colorYellow).Bold(true)
-			summaryStyle = lipgloss.NewStyle().Foreground(colorYellow)
-		case "Write":
-			nameStyle = lipgloss.NewStyle().Foreground(colorGreen).Bold(true)
-			summaryStyle = lipgloss.NewStyle().Foreground(colorGreen)
-		case "Read":
-			nameStyle = lipgloss.NewStyle().Foreground(colorCyan).Bold(true)
-		case "Bash":
-			nameStyle = lipgloss.NewStyle().Foreground(colorOrange).Bold(true)
-		}
-		return fmt.Sprintf("%s  %s  %s", tsStr, sel(nameStyle).Render(name), sel(summaryStyle).Render(summary))
+		summary = truncate(summary, maxWidth-26-len([]rune(outcome)))
+		nameStyle, summaryStyle := toolStyles(e)
+		return fmt.Sprintf("%s  %s  %s%s%s", tsStr, sel(nameStyle).Render(name), sel(summaryStyle).Render(summary), selBg.Render(" "), sel(outcomeStyle).Render(outcome))
 
 	case session.EventToolResult:
 		if e.IsError {
@@ -262,7 +279,8 @@ func formatEventLineSelected(e session.Event, maxWidth int) string {
 		return fmt.Sprintf("%s  %s  %s", tsStr, sel(dimStyle).Render("⚡ hook  "), sel(mutedStyle).Render(name))
 
 	case session.EventBashProgress:
-		return fmt.Sprintf("%s  %s  %s", tsStr, sel(dimStyle).Render("… bash  "), sel(mutedStyle).Render(fmt.Sprintf("%ds", e.BashElapsedSec)))
+		text := truncate(lastLine(e.BashOutput), maxWidth-30)
+		return fmt.Sprintf("%s  %s  %s %s", tsStr, sel(dimStyle).Render("… bash  "), sel(mutedStyle).Render(fmt.Sprintf("%ds", e.BashElapsedSec)), sel(dimStyle).Render(text))
 
 	case session.EventTurnDuration:
 		return fmt.Sprintf("%s  %s  %s", tsStr, sel(dimStyle).Render("⏱ turn  "), sel(mutedStyle).Render(fmt.Sprintf("%dms", e.TurnDurationMs)))
@@ -272,6 +290,59 @@ func formatEventLineSelected(e session.Event, maxWidth int) string {
 	}
 }
 
+// toolStyles colors a tool call's name and summary by what it does to files:
+// yellow for edits, green for creations, red for deletions.
+func toolStyles(e session.Event) (name, summary lipgloss.Style) {
+	name, summary = toolUseStyle, dimStyle
+	switch session.ClassifyTool(e.ToolName, e.ToolInput)[0].Op {
+	case session.OpModify:
+		name = lipgloss.NewStyle().Foreground(colorYellow).Bold(true)
+		summary = lipgloss.NewStyle().Foreground(colorYellow)
+	case session.OpCreate:
+		name = lipgloss.NewStyle().Foreground(colorGreen).Bold(true)
+		summary = lipgloss.NewStyle().Foreground(colorGreen)
+	case session.OpDelete:
+		name = lipgloss.NewStyle().Foreground(colorRed).Bold(true)
+		summary = lipgloss.NewStyle().Foreground(colorRed)
+	case session.OpRead:
+		name = lipgloss.NewStyle().Foreground(colorCyan).Bold(true)
+	case session.OpExecute:
+		name = lipgloss.NewStyle().Foreground(colorOrange).Bold(true)
+	}
+	return name, summary
+}
+
+// toolOutcome summarises how a tool call ended: ✓ or ✗ with its duration, or
+// … when no result has been recorded (still running, or interrupted).
+func toolOutcome(e session.Event) (string, lipgloss.Style) {
+	if e.PairIndex < 0 {
+		if e.BashElapsedSec > 0 {
+			return fmt.Sprintf("… %ds", e.BashElapsedSec), mutedStyle
+		}
+		return "…", mutedStyle
+	}
+	mark, style := "✓", toolResultStyle
+	if e.IsError {
+		mark, style = "✗", toolErrorStyle
+	}
+	if e.ToolDuration > 0 {
+		mark += " " + formatDuration(e.ToolDuration)
+	}
+	return mark, style
+}
+
+// formatDuration renders a tool call duration compactly: 230ms, 4.2s, 2m05s.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	default:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+}
+
 // visibleLen estimates the printable character count (strips ANSI escape sequences).
 func visibleLen(s string) int {
 	n := 0
@@ -294,10 +365,20 @@ func visibleLen(s string) int {
 
 func formatToolSummary(tool string, input map[string]interface{}) string {
 	switch tool {
-	case "Bash":
+	case "Bash", "shell", "shell_command", "exec_command", "run_shell_command":
 		if cmd, ok := input["command"].(string); ok {
 			return "$ " + firstLine(cmd)
 		}
+	case "apply_patch":
+		var paths []string
+		for _, a := range session.ClassifyTool(tool, input) {
+			if a.Path != "" {
+				paths = append(paths, a.Path)
+			}
+		}
+		if len(paths) > 0 {
+			return "✎ " + strings.Join(paths, ", ")
+		}
 	case "Read":
 		if fp, ok := input["file_path"].(string); ok {
 			return fp
@@ -306,7 +387,7 @@ func formatToolSummary(tool string, input map[string]interface{}) string {
 		if fp, ok := input["file_path"].(string); ok {
 			return "→ " + fp
 		}
-	case "Edit":
+	case "Edit", "MultiEdit":
 		if fp, ok := input["file_path"].(string); ok {
 			return "✎ " + fp
 		}
@@ -373,7 +454,7 @@ func formatToolSummary(tool string, input map[string]interface{}) string {
 		}
 	case "NotebookEdit":
 		if fp, ok := input["notebook_path"].(string); ok {
-			return fp
+			return "✎ " + fp
 		}
 	case "TaskStop":
 		if id, ok := input["task_id"].(string); ok {
@@ -385,8 +466,9 @@ func formatToolSummary(tool string, input map[string]interface{}) string {
 	return string(b)
 }
 
-// renderEventDetail renders the drill-down view for a single event.
-func renderEventDetail(e session.Event, scroll int, width, height int) string {
+// renderEventDetail renders the drill-down view for a single event. For tool
+// calls and results, pair is the other half of the call, if one was recorded.
+func renderEventDetail(e session.Event, pair *session.Event, scroll int, width, height int) string {
 	// Build all lines first, then apply scroll
 	var lines []string
 
@@ -412,27 +494,30 @@ func renderEventDetail(e session.Event, scroll int, width, height int) string {
 		lines = append(lines, wrapLines(e.Text, width-4, "  ")...)
 
 	case session.EventToolUse:
-		lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s — %s", e.ToolName, ts)))
+		outcome, outcomeStyle := toolOutcome(e)
+		lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s — %s", e.ToolName, ts))+" "+outcomeStyle.Render(outcome))
 		lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
 		lines = append(lines, "")
-
-		// Special rendering for Edit tool — show as diff
-		if e.ToolName == "Edit" {
-			lines = append(lines, renderEditDiff(e.ToolInput, width)...)
-		} else if e.ToolName == "Bash" {
-			if cmd, ok := e.ToolInput["command"].(string); ok {
-				lines = append(lines, "  "+dimStyle.Render("Command:"))
-				lines = append(lines, "  "+toolUseStyle.Render("$ "+cmd))
-			}
-			if desc, ok := e.ToolInput["description"].(string); ok && desc != "" {
-				lines = append(lines, "  "+dimStyle.Render("Description: ")+normalStyle.Render(desc))
+		lines = append(lines, renderToolInput(e, width)...)
+		if e.AgentID != "" {
+			lines = append(lines, "  "+dimStyle.Render("Subagent: ")+systemStyle.Render(e.AgentID)+dimStyle.Render(" (a to open)"))
+		}
+		lines = append(lines, "")
+		if pair != nil {
+			lines = append(lines, renderToolOutput(*pair, width)...)
+		} else if e.BashOutput != "" || e.BashElapsedSec > 0 {
+			// Still running: show the tail of the output so far, which grows
+			// as progress arrives
+			label := fmt.Sprintf("Running — %ds, live output (%s):", e.BashElapsedSec, formatBytes(len(e.BashOutput)))
+			lines = append(lines, "  "+dimStyle.Render(label), "")
+			output := wrapLines(e.BashOutput, width-4, "  ")
+			paneHeight := max(5, height-len(lines)-4)
+			if len(output) > paneHeight {
+				output = output[len(output)-paneHeight:]
 			}
+			lines = append(lines, output...)
 		} else {
-			lines = append(lines, "  "+dimStyle.Render("Input:"))
-			inputJSON, _ := json.MarshalIndent(e.ToolInput, "    ", "  ")
-			for _, line := range strings.Split(string(inputJSON), "\n") {
-				lines = append(lines, "    "+normalStyle.Render(line))
-			}
+			lines = append(lines, "  "+mutedStyle.Render("No result recorded yet."))
 		}
 
 	case session.EventToolResult:
@@ -440,12 +525,21 @@ func renderEventDetail(e session.Event, scroll int, width, height int) string {
 		if e.IsError {
 			title = "Tool Result (Error)"
 		}
-		lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s — %s", title, ts)))
+		header := headerStyle.Render(fmt.Sprintf(" %s — %s", title, ts))
+		if pair != nil {
+			header = headerStyle.Render(fmt.Sprintf(" %s %s — %s", pair.ToolName, title, ts))
+			if e.ToolDuration > 0 {
+				header += " " + mutedStyle.Render(formatDuration(e.ToolDuration))
+			}
+		}
+		lines = append(lines, header)
 		lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
 		lines = append(lines, "")
-		lines = append(lines, "  "+dimStyle.Render(fmt.Sprintf("Output (%s):", formatBytes(len(e.ToolOutput)))))
-		lines = append(lines, "")
-		lines = append(lines, wrapLines(e.ToolOutput, width-4, "  ")...)
+		if pair != nil {
+			lines = append(lines, renderToolInput(*pair, width)...)
+			lines = append(lines, "")
+		}
+		lines = append(lines, renderToolOutput(e, width)...)
 
 	case session.EventCompaction:
 		lines = append(lines, headerStyle.Render(fmt.Sprintf(" Conversation Compacted — %s", ts)))
@@ -492,6 +586,11 @@ func renderEventDetail(e session.Event, scroll int, width, height int) string {
 		lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
 		lines = append(lines, "")
 		lines = append(lines, fieldLine("Elapsed", fmt.Sprintf("%ds", e.BashElapsedSec)))
+		if e.BashOutput != "" {
+			lines = append(lines, "")
+			lines = append(lines, "  "+dimStyle.Render(fmt.Sprintf("Output so far (%s):", formatBytes(len(e.BashOutput)))), "")
+			lines = append(lines, wrapLines(e.BashOutput, width-4, "  ")...)
+		}
 
 	case session.EventTurnDuration:
 		lines = append(lines, headerStyle.Render(fmt.Sprintf(" Turn Duration — %s", ts)))
@@ -537,48 +636,120 @@ func renderEventDetail(e session.Event, scroll int, width, height int) string {
 	return strings.Join(visible, "\n")
 }
 
-// renderEditDiff shows Edit tool input as a colored diff.
+// renderToolInput renders the input of a tool call. Edits and patches show
+// as a diff whichever agent made them.
+func renderToolInput(e session.Event, width int) []string {
+	var lines []string
+
+	cmd, _ := e.ToolInput["command"].(string)
+	switch op := session.ClassifyTool(e.ToolName, e.ToolInput)[0].Op; {
+	case op == session.OpModify && len(session.EditChanges(e.ToolInput)) > 0:
+		lines = append(lines, renderEditDiff(e.ToolInput, width)...)
+	case (op == session.OpModify || op == session.OpCreate || op == session.OpDelete) && session.PatchText(e.ToolInput) != "":
+		lines = append(lines, renderPatch(e.ToolInput, width)...)
+	case op == session.OpExecute && cmd != "":
+		lines = append(lines, "  "+dimStyle.Render("Command:"))
+		lines = append(lines, "  "+toolUseStyle.Render("$ "+cmd))
+		if desc, ok := e.ToolInput["description"].(string); ok && desc != "" {
+			lines = append(lines, "  "+dimStyle.Render("Description: ")+normalStyle.Render(desc))
+		}
+	default:
+		lines = append(lines, "  "+dimStyle.Render("Input:"))
+		inputJSON, _ := json.MarshalIndent(e.ToolInput, "    ", "  ")
+		for _, line := range strings.Split(string(inputJSON), "\n") {
+			lines = append(lines, "    "+normalStyle.Render(line))
+		}
+	}
+
+	return lines
+}
+
+// renderToolOutput renders the output of a tool result. A large output that
+// could not be read back in full shows its preview.
+func renderToolOutput(e session.Event, width int) []string {
+	size := formatBytes(len(e.ToolOutput))
+	if e.Truncated() {
+		size = fmt.Sprintf("first %s of %s", size, formatBytes(e.OutputSize))
+	}
+	label := fmt.Sprintf("Output (%s):", size)
+	if e.IsError {
+		label = toolErrorStyle.Render(fmt.Sprintf("Error (%s):", size))
+	} else {
+		label = dimStyle.Render(label)
+	}
+
+	lines := []string{"  " + label, ""}
+	return append(lines, wrapLines(e.ToolOutput, width-4, "  ")...)
+}
+
+// renderEditDiff shows the replacements of an edit call as a colored diff,
+// one block per replacement of a MultiEdit.
 func renderEditDiff(input map[string]interface{}, width int) []string {
 	var lines []string
 
 	fp, _ := input["file_path"].(string)
-	oldStr, _ := input["old_string"].(string)
-	newStr, _ := input["new_string"].(string)
-
+	if fp == "" {
+		fp, _ = input["filePath"].(string)
+	}
 	if fp != "" {
 		lines = append(lines, "  "+dimStyle.Render("File: ")+toolUseStyle.Render(fp))
 		lines = append(lines, "")
 	}
 
-	if oldStr != "" || newStr != "" {
-		maxW := min(width-6, 120)
-
-		if oldStr != "" {
+	maxW := min(width-6, 120)
+	for i, c := range session.EditChanges(input) {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		if c.Old != "" {
 			lines = append(lines, "  "+diffRemoveStyle.Render("--- removed"))
-			for _, l := range strings.Split(oldStr, "\n") {
+			for _, l := range strings.Split(c.Old, "\n") {
 				if len(l) > maxW {
 					l = l[:maxW]
 				}
 				lines = append(lines, "  "+diffRemoveStyle.Render("- "+l))
 			}
 		}
-		if newStr != "" {
+		if c.New != "" {
 			lines = append(lines, "  "+diffAddStyle.Render("+++ added"))
-			for _, l := range strings.Split(newStr, "\n") {
+			for _, l := range strings.Split(c.New, "\n") {
 				if len(l) > maxW {
 					l = l[:maxW]
 				}
 				lines = append(lines, "  "+diffAddStyle.Render("+ "+l))
 			}
 		}
+		if c.ReplaceAll {
+			lines = append(lines, "")
+			lines = append(lines, "  "+systemStyle.Render("(replace_all: true)"))
+		}
 	}
 
-	replaceAll, _ := input["replace_all"].(bool)
-	if replaceAll {
-		lines = append(lines, "")
-		lines = append(lines, "  "+systemStyle.Render("(replace_all: true)"))
-	}
+	return lines
+}
 
+// renderPatch shows an apply_patch envelope with its added and removed lines
+// colored.
+func renderPatch(input map[string]interface{}, width int) []string {
+	patch := session.PatchText(input)
+	maxW := min(width-6, 120)
+
+	var lines []string
+	for _, l := range strings.Split(strings.TrimRight(patch, "\n"), "\n") {
+		if len(l) > maxW {
+			l = l[:maxW]
+		}
+		style := diffContextStyle
+		switch {
+		case strings.HasPrefix(l, "***"), strings.HasPrefix(l, "@@"):
+			style = toolUseStyle
+		case strings.HasPrefix(l, "+"):
+			style = diffAddStyle
+		case strings.HasPrefix(l, "-"):
+			style = diffRemoveStyle
+		}
+		lines = append(lines, "  "+style.Render(l))
+	}
 	return lines
 }
 
@@ -596,6 +767,15 @@ func firstLine(s string) string {
 	return s
 }
 
+// lastLine returns the last non-blank line of s.
+func lastLine(s string) string {
+	s = strings.TrimRight(s, " \t\r\n")
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
 func truncate(s string, maxLen int) string {
 	if maxLen <= 0 {
 		maxLen = 40
@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fooxytv/verbose/internal/session"
+)
+
+// renderFileList renders the files a session modified, for picking one to
+// step through.
+func renderFileList(sess *session.Session, histories []session.FileHistory, cursor int, width, height int) string {
+	info := sess.Info
+	var lines []string
+
+	lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s > %s  Files (%d)", info.ProjectName, shortID(info.ID), len(histories))))
+	lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
+
+	if len(histories) == 0 {
+		lines = append(lines, "")
+		lines = append(lines, dimStyle.Render("  No files were modified on this branch of the session."))
+		return strings.Join(lines, "\n")
+	}
+
+	for i, h := range histories {
+		steps := fmt.Sprintf("%d steps", len(h.Versions))
+		if len(h.Versions) == 1 {
+			steps = "1 step"
+		}
+		known := mutedStyle.Render("full content")
+		if !h.Reconstructed() {
+			known = systemStyle.Render("edits only")
+		}
+		line := fmt.Sprintf("%-60s  %8s  %s", truncate(relativePath(h.Path, info.CWD), 60), steps, known)
+		if i == cursor {
+			lines = append(lines, selectedStyle.Render("▸ "+line))
+		} else {
+			lines = append(lines, normalStyle.Render("  "+line))
+		}
+	}
+
+	// Keep the cursor in view
+	visibleHeight := max(1, height-3)
+	start := 0
+	if cursor+2 >= visibleHeight {
+		start = cursor + 2 - visibleHeight + 1
+	}
+	end := min(len(lines), start+visibleHeight)
+	return strings.Join(lines[start:end], "\n")
+}
+
+// renderFileHistory renders one version of a file: the diff from the previous
+// step and, when the content is known, the whole file after the step.
+func renderFileHistory(sess *session.Session, h session.FileHistory, step, scroll int, width, height int) string {
+	var lines []string
+	v := h.Versions[step]
+
+	lines = append(lines, headerStyle.Render(fmt.Sprintf(" %s > %s  %s", sess.Info.ProjectName, shortID(sess.Info.ID), relativePath(h.Path, sess.Info.CWD))))
+	lines = append(lines, "  "+mutedStyle.Render(fmt.Sprintf("step %d/%d · %s · %s", step+1, len(h.Versions), v.ToolName, v.Timestamp.Format("15:04:05"))))
+	lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 100))))
+	lines = append(lines, "")
+
+	if !v.Known {
+		lines = append(lines, "  "+systemStyle.Render("Content unknown: the file existed before this step and was never read in full"))
+		lines = append(lines, "  "+systemStyle.Render("or was changed outside the session, so only the edited fragments are shown."))
+		lines = append(lines, "")
+	}
+	if v.Failed {
+		lines = append(lines, "  "+toolErrorStyle.Render(fmt.Sprintf("This %s failed; the file was not changed.", v.ToolName)))
+		lines = append(lines, "")
+	}
+
+	// Diff from the previous step
+	lines = append(lines, sectionHeader("Changes"))
+	if len(v.Changes) == 0 && !v.Failed {
+		lines = append(lines, "  "+mutedStyle.Render("No content change recorded for this step."))
+	}
+	for _, c := range v.Changes {
+		lines = append(lines, renderEditDiff(map[string]interface{}{
+			"old_string":  c.Old,
+			"new_string":  c.New,
+			"replace_all": c.ReplaceAll,
+		}, width)...)
+		lines = append(lines, "")
+	}
+
+	// The whole file after this step
+	if v.Known {
+		lines = append(lines, "")
+		lines = append(lines, sectionHeader(fmt.Sprintf("Content after step (%s)", formatBytes(len(v.Content)))))
+		content := strings.TrimSuffix(v.Content, "\n")
+		if content == "" {
+			lines = append(lines, "  "+mutedStyle.Render("(empty)"))
+		} else {
+			maxW := min(width-10, 120)
+			for i, l := range strings.Split(content, "\n") {
+				if len(l) > maxW {
+					l = l[:maxW]
+				}
+				lines = append(lines, mutedStyle.Render(fmt.Sprintf("  %5d ", i+1))+normalStyle.Render(l))
+			}
+		}
+	}
+
+	// Apply scroll
+	visibleHeight := height - 3
+	if visibleHeight < 1 {
+		visibleHeight = 1
+	}
+	if scroll > len(lines)-visibleHeight {
+		scroll = max(0, len(lines)-visibleHeight)
+	}
+	end := scroll + visibleHeight
+	if end > len(lines) {
+		end = len(lines)
+	}
+	visible := lines[scroll:end]
+
+	// Scroll indicator
+	if len(lines) > visibleHeight {
+		pct := float64(scroll+visibleHeight) / float64(len(lines)) * 100
+		if pct > 100 {
+			pct = 100
+		}
+		visible = append(visible, mutedStyle.Render(fmt.Sprintf("  [%.0f%%]", pct)))
+	}
+
+	return strings.Join(visible, "\n")
+}
+
+// relativePath shortens a path to be relative to cwd when it is inside it.
+func relativePath(path, cwd string) string {
+	if cwd == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
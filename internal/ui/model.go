@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/fooxytv/verbose/internal/session"
 
@@ -11,39 +12,83 @@ import (
 type viewMode int
 
 const (
-	viewSessions viewMode = iota
-	viewDetail            // event timeline (default when opening a session)
-	viewOverview          // session summary (opt-in via "s")
-	viewEvent             // single event drill-down
-	viewProject           // project-level view
+	viewSessions    viewMode = iota
+	viewDetail               // event timeline (default when opening a session)
+	viewOverview             // session summary (opt-in via "s")
+	viewEvent                // single event drill-down
+	viewProject              // project-level view
+	viewFiles                // files the session modified
+	viewFileHistory          // one file's versions, step by step
+	viewCheckpoints          // file checkpoints taken before prompts
+	viewCheckpoint           // one checkpoint's files compared with disk
 )
 
-// sessionsUpdatedMsg signals that the session store has new data.
-type sessionsUpdatedMsg struct{}
+// detailFrame remembers where the timeline was when a subagent was opened.
+type detailFrame struct {
+	session *session.Session
+	branch  int
+	cursor  int
+}
+
+// sessionsUpdatedMsg signals that the session store has new data. A nil
+// changes means everything should be re-read.
+type sessionsUpdatedMsg struct {
+	changes []session.SessionChange
+}
 
 // Model is the main bubbletea model.
 type Model struct {
 	store   *session.Store
-	updates <-chan struct{}
+	updates <-chan []session.SessionChange
 
 	mode viewMode
 
-	// Sessions list
-	sessions []session.SessionInfo
+	// Sessions list, kept current from the store's change batches
+	list     session.SessionList
+	sessions []session.SessionInfo // list, filtered to projectFilter
 	cursor   int
 
 	// Session detail + overview
 	selectedSession *session.Session
+	timeline        []int            // indices into selectedSession.Events on the shown branch
+	branches        []session.Branch // conversation branches, active first
+	branch          int              // index into branches of the branch shown
 	detailCursor    int
 	overviewScroll  int
 
+	// Parent timelines to return to after drilling into a subagent
+	parents []detailFrame
+
 	// Event detail
 	selectedEvent *session.Event
+	selectedPair  *session.Event // matching tool call/result of selectedEvent
 	eventScroll   int
 
+	// Full output of the last large tool result opened, see loadFullOutput
+	fullOutputKey string
+	fullOutput    string
+
 	// Auto-follow: scroll to bottom on updates
 	autoFollow bool
 
+	// File history: histories of the shown branch, selected file and step
+	fileHistories []session.FileHistory
+	fileCursor    int
+	fileStep      int
+	fileScroll    int
+
+	// Checkpoints: selected checkpoint and file, and a pending restore
+	checkpointCursor int
+	checkpointFile   int
+	checkpointScroll int
+	confirmRestore   bool
+	restoreStatus    string
+
+	// The open checkpoint's files compared with disk, and those a restore
+	// would skip as outside the session's directories
+	checkpointCompared []session.Comparison
+	checkpointOutside  []string
+
 	// Project view
 	selectedProject *session.ProjectInfo
 	projectScroll   int
@@ -70,8 +115,9 @@ func NewModel(store *session.Store, projectFilter, version string) Model {
 	}
 }
 
-// SetUpdates sets the channel for receiving session update notifications.
-func (m *Model) SetUpdates(ch <-chan struct{}) {
+// SetUpdates sets the channel for receiving session changes, usually a
+// Subscription's C.
+func (m *Model) SetUpdates(ch <-chan []session.SessionChange) {
 	m.updates = ch
 }
 
@@ -95,10 +141,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleMouse(msg)
 
 	case sessionsUpdatedMsg:
-		m.refreshSessions()
+		m.refreshSessions(msg.changes)
 		// Auto-scroll to bottom when in detail view (follow live output)
 		if m.mode == viewDetail && m.selectedSession != nil && m.autoFollow {
-			m.detailCursor = max(0, len(m.selectedSession.Events)-1)
+			m.detailCursor = max(0, len(m.timeline)-1)
 		}
 		return m, m.watchForUpdates
 
@@ -137,13 +183,13 @@ func (m Model) View() string {
 
 	case viewDetail:
 		if m.selectedSession != nil {
-			content = renderSessionDetail(m.selectedSession, m.detailCursor, m.width, m.height)
+			content = renderSessionDetail(m.selectedSession, m.timeline, m.branches, m.branch, m.detailCursor, m.width, m.height)
 		}
 		followLabel := "follow"
 		if m.autoFollow {
 			followLabel = "follow ●"
 		}
-		help = renderHelp([]helpKey{
+		keys := []helpKey{
 			{"↑/↓", "navigate"},
 			{"→/enter/space", "expand"},
 			{"←", "back"},
@@ -151,8 +197,18 @@ func (m Model) View() string {
 			{"p", "project"},
 			{"c", "continue"},
 			{"f", followLabel},
-			{"q", "quit"},
-		})
+			{"F", "files"},
+		}
+		if m.selectedSession != nil && len(m.selectedSession.Checkpoints) > 0 {
+			keys = append(keys, helpKey{"C", "checkpoints"})
+		}
+		if len(m.branches) > 1 {
+			keys = append(keys, helpKey{"b/B", "branches"})
+		}
+		if m.cursorEvent().AgentID != "" {
+			keys = append(keys, helpKey{"a", "agent"})
+		}
+		help = renderHelp(append(keys, helpKey{"q", "quit"}))
 
 	case viewOverview:
 		if m.selectedSession != nil {
@@ -167,15 +223,65 @@ func (m Model) View() string {
 			{"↑/↓", "scroll"},
 			{"←/esc", "back"},
 			{"p", "project"},
+			{"F", "files"},
 			{"q", "quit"},
 		})
 
 	case viewEvent:
 		if m.selectedEvent != nil {
-			content = renderEventDetail(*m.selectedEvent, m.eventScroll, m.width, m.height)
+			content = renderEventDetail(*m.selectedEvent, m.selectedPair, m.eventScroll, m.width, m.height)
+		}
+		keys := []helpKey{
+			{"↑/↓", "scroll"},
+			{"←", "back"},
+		}
+		if m.selectedEvent != nil && m.selectedEvent.AgentID != "" {
+			keys = append(keys, helpKey{"a", "agent"})
+		}
+		help = renderHelp(append(keys, helpKey{"q", "quit"}))
+
+	case viewFiles:
+		if m.selectedSession != nil {
+			content = renderFileList(m.selectedSession, m.fileHistories, m.fileCursor, m.width, m.height)
+		}
+		help = renderHelp([]helpKey{
+			{"↑/↓", "navigate"},
+			{"→/enter/space", "history"},
+			{"←", "back"},
+			{"q", "quit"},
+		})
+
+	case viewFileHistory:
+		if m.selectedSession != nil && m.fileCursor < len(m.fileHistories) {
+			content = renderFileHistory(m.selectedSession, m.fileHistories[m.fileCursor], m.fileStep, m.fileScroll, m.width, m.height)
+		}
+		help = renderHelp([]helpKey{
+			{"↑/↓", "scroll"},
+			{"tab/shift+tab", "next/prev step"},
+			{"←", "back"},
+			{"q", "quit"},
+		})
+
+	case viewCheckpoints:
+		if m.selectedSession != nil {
+			content = renderCheckpointList(m.selectedSession, m.checkpointCursor, m.width, m.height)
+		}
+		help = renderHelp([]helpKey{
+			{"↑/↓", "navigate"},
+			{"→/enter/space", "open"},
+			{"←", "back"},
+			{"q", "quit"},
+		})
+
+	case viewCheckpoint:
+		if m.selectedSession != nil && m.checkpointCursor < len(m.selectedSession.Checkpoints) {
+			cp := m.selectedSession.Checkpoints[m.checkpointCursor]
+			content = renderCheckpoint(m.selectedSession, cp, m.checkpointCompared, m.checkpointOutside, m.checkpointFile, m.checkpointScroll, m.confirmRestore, m.restoreStatus, m.width, m.height)
 		}
 		help = renderHelp([]helpKey{
 			{"↑/↓", "scroll"},
+			{"tab/shift+tab", "select file"},
+			{"R", "restore"},
 			{"←", "back"},
 			{"q", "quit"},
 		})
@@ -206,6 +312,16 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		key = "enter"
 	}
 
+	// A restore only happens on an explicit "y"; any other key cancels it
+	if m.confirmRestore {
+		m.confirmRestore = false
+		m.restoreStatus = "Restore cancelled."
+		if key == "y" {
+			m.restoreCheckpoint()
+		}
+		return m, nil
+	}
+
 	switch key {
 	case "q", "ctrl+c":
 		return m, tea.Quit
@@ -213,8 +329,23 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "esc", "left":
 		switch m.mode {
 		case viewDetail:
+			// Return to the parent timeline when inside a subagent
+			if n := len(m.parents); n > 0 {
+				frame := m.parents[n-1]
+				m.parents = m.parents[:n-1]
+				m.selectedSession = nil
+				m.openSession(frame.session)
+				m.branch = min(frame.branch, max(0, len(m.branches)-1))
+				m.showBranch()
+				m.detailCursor = min(frame.cursor, max(0, len(m.timeline)-1))
+				m.autoFollow = false
+				break
+			}
 			m.mode = viewSessions
 			m.selectedSession = nil
+			m.timeline = nil
+			m.branches = nil
+			m.branch = 0
 			m.detailCursor = 0
 			m.autoFollow = false
 		case viewOverview:
@@ -229,11 +360,24 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case viewEvent:
 			m.mode = viewDetail
 			m.selectedEvent = nil
+			m.selectedPair = nil
 			m.eventScroll = 0
 		case viewProject:
 			m.mode = viewSessions
 			m.selectedProject = nil
 			m.projectScroll = 0
+		case viewFiles:
+			m.mode = viewDetail
+			m.fileHistories = nil
+		case viewFileHistory:
+			m.mode = viewFiles
+			m.fileScroll = 0
+		case viewCheckpoints:
+			m.mode = viewDetail
+		case viewCheckpoint:
+			m.mode = viewCheckpoints
+			m.checkpointScroll = 0
+			m.restoreStatus = ""
 		}
 
 	case "j", "down":
@@ -245,13 +389,25 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case viewOverview:
 			m.overviewScroll++
 		case viewDetail:
-			if m.selectedSession != nil && m.detailCursor < len(m.selectedSession.Events)-1 {
+			if m.selectedSession != nil && m.detailCursor < len(m.timeline)-1 {
 				m.detailCursor++
 			}
 		case viewEvent:
 			m.eventScroll++
 		case viewProject:
 			m.projectScroll++
+		case viewFiles:
+			if m.fileCursor < len(m.fileHistories)-1 {
+				m.fileCursor++
+			}
+		case viewFileHistory:
+			m.fileScroll++
+		case viewCheckpoints:
+			if m.selectedSession != nil && m.checkpointCursor < len(m.selectedSession.Checkpoints)-1 {
+				m.checkpointCursor++
+			}
+		case viewCheckpoint:
+			m.checkpointScroll++
 		}
 
 	case "k", "up":
@@ -277,6 +433,22 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if m.projectScroll > 0 {
 				m.projectScroll--
 			}
+		case viewFiles:
+			if m.fileCursor > 0 {
+				m.fileCursor--
+			}
+		case viewFileHistory:
+			if m.fileScroll > 0 {
+				m.fileScroll--
+			}
+		case viewCheckpoints:
+			if m.checkpointCursor > 0 {
+				m.checkpointCursor--
+			}
+		case viewCheckpoint:
+			if m.checkpointScroll > 0 {
+				m.checkpointScroll--
+			}
 		}
 
 	case "g", "home":
@@ -291,6 +463,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.eventScroll = 0
 		case viewProject:
 			m.projectScroll = 0
+		case viewFiles:
+			m.fileCursor = 0
+		case viewFileHistory:
+			m.fileScroll = 0
 		}
 
 	case "G", "end":
@@ -300,11 +476,13 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.cursor = len(m.sessions) - 1
 			}
 		case viewDetail:
-			if m.selectedSession != nil && len(m.selectedSession.Events) > 0 {
-				m.detailCursor = len(m.selectedSession.Events) - 1
+			if m.selectedSession != nil && len(m.timeline) > 0 {
+				m.detailCursor = len(m.timeline) - 1
 			}
 		case viewProject:
 			m.projectScroll = 99999 // will be clamped by renderer
+		case viewFiles:
+			m.fileCursor = max(0, len(m.fileHistories)-1)
 		}
 
 	case "enter", "right":
@@ -315,26 +493,48 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				info := m.sessions[m.cursor]
 				sess := m.store.GetSession(info.ID)
 				if sess != nil {
-					m.selectedSession = sess
-					m.detailCursor = max(0, len(sess.Events)-1)
+					m.parents = nil
+					m.openSession(sess)
+					m.detailCursor = max(0, len(m.timeline)-1)
 					m.autoFollow = true
 					m.mode = viewDetail
 				}
 			}
 		case viewDetail:
-			if m.selectedSession != nil && m.detailCursor < len(m.selectedSession.Events) {
-				evt := m.selectedSession.Events[m.detailCursor]
+			if m.selectedSession != nil && m.detailCursor < len(m.timeline) {
+				idx := m.timeline[m.detailCursor]
+				evt := m.selectedSession.Events[idx]
 				m.selectedEvent = &evt
+				m.selectedPair = nil
+				if pair, ok := m.selectedSession.Pair(idx); ok {
+					m.selectedPair = &pair
+				}
+				m.loadFullOutput()
 				m.eventScroll = 0
 				m.mode = viewEvent
 			}
+		case viewFiles:
+			if m.fileCursor < len(m.fileHistories) {
+				m.fileStep = 0
+				m.fileScroll = 0
+				m.mode = viewFileHistory
+			}
+		case viewCheckpoints:
+			if m.selectedSession != nil && m.checkpointCursor < len(m.selectedSession.Checkpoints) {
+				m.checkpointFile = 0
+				m.checkpointScroll = 0
+				m.restoreStatus = ""
+				m.compareCheckpoint()
+				m.mode = viewCheckpoint
+			}
 		case viewProject:
 			if m.selectedProject != nil && m.projectCursor < len(m.selectedProject.Sessions) {
 				info := m.selectedProject.Sessions[m.projectCursor]
 				sess := m.store.GetSession(info.ID)
 				if sess != nil {
-					m.selectedSession = sess
-					m.detailCursor = max(0, len(sess.Events)-1)
+					m.parents = nil
+					m.openSession(sess)
+					m.detailCursor = max(0, len(m.timeline)-1)
 					m.autoFollow = true
 					m.mode = viewDetail
 				}
@@ -349,7 +549,8 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				info := m.sessions[m.cursor]
 				sess := m.store.GetSession(info.ID)
 				if sess != nil {
-					m.selectedSession = sess
+					m.parents = nil
+					m.openSession(sess)
 					m.sessionTodos = m.store.GetSessionTodos(info.ID)
 					m.overviewScroll = 0
 					m.mode = viewOverview
@@ -410,26 +611,110 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		}
 		if info != nil {
-			return m, resumeSessionCmd(info.ID, info.CWD)
+			if cli, args, ok := m.store.ResumeCommand(*info); ok {
+				return m, resumeSessionCmd(cli, args, info.ID, info.CWD)
+			}
 		}
 
 	case "f":
 		if m.mode == viewDetail {
 			m.autoFollow = !m.autoFollow
 			if m.autoFollow && m.selectedSession != nil {
-				m.detailCursor = max(0, len(m.selectedSession.Events)-1)
+				m.detailCursor = max(0, len(m.timeline)-1)
 			}
 		}
 
+	case "a":
+		// Drill into the subagent spawned by the selected Task call
+		var evt session.Event
+		switch m.mode {
+		case viewDetail:
+			evt = m.cursorEvent()
+		case viewEvent:
+			if m.selectedEvent != nil {
+				evt = *m.selectedEvent
+			}
+		}
+		if evt.AgentID != "" && m.selectedSession != nil {
+			if sub := m.store.GetSubagent(m.selectedSession.Info.ID, evt.AgentID); sub != nil {
+				m.parents = append(m.parents, detailFrame{
+					session: m.selectedSession,
+					branch:  m.branch,
+					cursor:  m.detailCursor,
+				})
+				m.openSession(sub)
+				m.detailCursor = max(0, len(m.timeline)-1)
+				m.autoFollow = false
+				m.selectedEvent = nil
+				m.selectedPair = nil
+				m.eventScroll = 0
+				m.mode = viewDetail
+			}
+		}
+
+	case "F":
+		// List the files modified on the shown branch
+		if (m.mode == viewDetail || m.mode == viewOverview) && m.selectedSession != nil {
+			m.fileHistories = m.selectedSession.FileHistories(m.timeline)
+			m.fileCursor = 0
+			m.mode = viewFiles
+		}
+
+	case "C":
+		// List the file checkpoints Claude Code took before prompts
+		if (m.mode == viewDetail || m.mode == viewOverview) && m.selectedSession != nil {
+			m.checkpointCursor = max(0, len(m.selectedSession.Checkpoints)-1)
+			m.mode = viewCheckpoints
+		}
+
+	case "R":
+		// Ask before writing a checkpoint back to disk
+		if m.mode == viewCheckpoint && m.selectedSession != nil && m.checkpointCursor < len(m.selectedSession.Checkpoints) {
+			m.confirmRestore = true
+		}
+
+	case "b", "B":
+		// Browse conversation branches: active first, then abandoned ones
+		if m.mode == viewDetail && len(m.branches) > 1 {
+			if key == "b" {
+				m.branch = (m.branch + 1) % len(m.branches)
+			} else {
+				m.branch = (m.branch - 1 + len(m.branches)) % len(m.branches)
+			}
+			m.showBranch()
+			m.detailCursor = max(0, len(m.timeline)-1)
+			m.autoFollow = m.branches[m.branch].Active
+		}
+
 	case "r":
-		m.refreshSessions()
+		m.refreshSessions(nil)
 
 	case "tab":
+		if m.mode == viewCheckpoint && m.selectedSession != nil && m.checkpointCursor < len(m.selectedSession.Checkpoints) {
+			if n := len(m.selectedSession.Checkpoints[m.checkpointCursor].Files); n > 0 {
+				m.checkpointFile = (m.checkpointFile + 1) % n
+			}
+		}
+		if m.mode == viewFileHistory && m.fileCursor < len(m.fileHistories) {
+			if m.fileStep < len(m.fileHistories[m.fileCursor].Versions)-1 {
+				m.fileStep++
+				m.fileScroll = 0
+			}
+		}
 		if m.mode == viewProject && m.selectedProject != nil && len(m.selectedProject.Sessions) > 0 {
 			m.projectCursor = (m.projectCursor + 1) % len(m.selectedProject.Sessions)
 		}
 
 	case "shift+tab":
+		if m.mode == viewCheckpoint && m.selectedSession != nil && m.checkpointCursor < len(m.selectedSession.Checkpoints) {
+			if n := len(m.selectedSession.Checkpoints[m.checkpointCursor].Files); n > 0 {
+				m.checkpointFile = (m.checkpointFile - 1 + n) % n
+			}
+		}
+		if m.mode == viewFileHistory && m.fileStep > 0 {
+			m.fileStep--
+			m.fileScroll = 0
+		}
 		if m.mode == viewProject && m.selectedProject != nil && len(m.selectedProject.Sessions) > 0 {
 			m.projectCursor = (m.projectCursor - 1 + len(m.selectedProject.Sessions)) % len(m.selectedProject.Sessions)
 		}
@@ -448,6 +733,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.eventScroll = max(0, m.eventScroll-pageSize)
 		case viewProject:
 			m.projectScroll = max(0, m.projectScroll-pageSize)
+		case viewFiles:
+			m.fileCursor = max(0, m.fileCursor-pageSize)
+		case viewFileHistory:
+			m.fileScroll = max(0, m.fileScroll-pageSize)
 		}
 
 	case "shift+down", "pgdown":
@@ -458,8 +747,8 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.cursor = min(len(m.sessions)-1, m.cursor+pageSize)
 			}
 		case viewDetail:
-			if m.selectedSession != nil && len(m.selectedSession.Events) > 0 {
-				m.detailCursor = min(len(m.selectedSession.Events)-1, m.detailCursor+pageSize)
+			if m.selectedSession != nil && len(m.timeline) > 0 {
+				m.detailCursor = min(len(m.timeline)-1, m.detailCursor+pageSize)
 			}
 		case viewOverview:
 			m.overviewScroll += pageSize
@@ -467,6 +756,12 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.eventScroll += pageSize
 		case viewProject:
 			m.projectScroll += pageSize
+		case viewFiles:
+			if len(m.fileHistories) > 0 {
+				m.fileCursor = min(len(m.fileHistories)-1, m.fileCursor+pageSize)
+			}
+		case viewFileHistory:
+			m.fileScroll += pageSize
 		}
 	}
 
@@ -498,6 +793,14 @@ func (m Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 			if m.projectScroll > 0 {
 				m.projectScroll--
 			}
+		case viewFiles:
+			if m.fileCursor > 0 {
+				m.fileCursor--
+			}
+		case viewFileHistory:
+			if m.fileScroll > 0 {
+				m.fileScroll--
+			}
 		}
 
 	case tea.MouseButtonWheelDown:
@@ -507,7 +810,7 @@ func (m Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 				m.cursor++
 			}
 		case viewDetail:
-			if m.selectedSession != nil && m.detailCursor < len(m.selectedSession.Events)-1 {
+			if m.selectedSession != nil && m.detailCursor < len(m.timeline)-1 {
 				m.detailCursor++
 			}
 		case viewOverview:
@@ -516,6 +819,12 @@ func (m Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 			m.eventScroll++
 		case viewProject:
 			m.projectScroll++
+		case viewFiles:
+			if m.fileCursor < len(m.fileHistories)-1 {
+				m.fileCursor++
+			}
+		case viewFileHistory:
+			m.fileScroll++
 		}
 	}
 	return m, nil
@@ -529,13 +838,21 @@ func (m Model) pageSize() int {
 	return ps
 }
 
-func (m *Model) refreshSessions() {
-	sessions := m.store.GetSessions()
+// refreshSessions applies changes to the session list and, when they mention
+// it or one of its subagents, re-reads the open session. A nil changes
+// re-reads everything from the store.
+func (m *Model) refreshSessions(changes []session.SessionChange) {
+	if changes == nil {
+		m.list = session.NewSessionList(m.store.GetSessions())
+	} else {
+		m.list.Apply(changes)
+	}
+	sessions := m.list.Sessions()
 
 	if m.projectFilter != "" {
 		var filtered []session.SessionInfo
 		for _, s := range sessions {
-			if s.ProjectName == m.projectFilter || s.ProjectDir == m.projectFilter {
+			if s.ProjectName == m.projectFilter || s.ProjectDir == filepath.Clean(m.projectFilter) {
 				filtered = append(filtered, s)
 			}
 		}
@@ -547,14 +864,146 @@ func (m *Model) refreshSessions() {
 		m.cursor = max(0, len(m.sessions)-1)
 	}
 
-	if m.selectedSession != nil {
+	if m.selectedSession != nil && touches(changes, m.selectedSession.Info.ID) {
 		updated := m.store.GetSession(m.selectedSession.Info.ID)
 		if updated != nil {
-			m.selectedSession = updated
+			m.openSession(updated)
+			m.refreshEvent()
 		}
 	}
 }
 
+// touches reports whether changes affect the session id, counting changes to
+// its subagents, whose usage rolls up into it.
+func touches(changes []session.SessionChange, id string) bool {
+	if changes == nil {
+		return true
+	}
+	for _, c := range changes {
+		if c.ID == id || c.Info.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshEvent re-reads the tool call or result open in the event view, so a
+// running command's output and its eventual result show up live.
+func (m *Model) refreshEvent() {
+	if m.selectedEvent == nil || m.selectedEvent.ToolID == "" {
+		return
+	}
+	if m.selectedEvent.Type != session.EventToolUse && m.selectedEvent.Type != session.EventToolResult {
+		return
+	}
+	for i, e := range m.selectedSession.Events {
+		if e.Type == m.selectedEvent.Type && e.ToolID == m.selectedEvent.ToolID {
+			m.selectedEvent = &e
+			m.selectedPair = nil
+			if pair, ok := m.selectedSession.Pair(i); ok {
+				m.selectedPair = &pair
+			}
+			m.loadFullOutput()
+			return
+		}
+	}
+}
+
+// loadFullOutput swaps the preview of a large tool output in the event view
+// for the full output, read back from the transcript. The last one read is
+// kept, so live refreshes of the view do not read it again. If it cannot be
+// read, the preview stays.
+func (m *Model) loadFullOutput() {
+	for _, e := range []*session.Event{m.selectedEvent, m.selectedPair} {
+		if e == nil || !e.Truncated() {
+			continue
+		}
+		key := m.selectedSession.Info.ID + "/" + e.ToolID
+		if key != m.fullOutputKey {
+			output, err := m.store.FullToolOutput(m.selectedSession.Info, *e)
+			if err != nil {
+				continue
+			}
+			m.fullOutputKey, m.fullOutput = key, output
+		}
+		e.ToolOutput = m.fullOutput
+	}
+}
+
+// openSession selects a session for the timeline and overview. When the same
+// session is refreshed, an abandoned branch being browsed stays selected;
+// otherwise the active branch is shown.
+func (m *Model) openSession(sess *session.Session) {
+	leaf := ""
+	if m.selectedSession != nil && m.selectedSession.Info.ID == sess.Info.ID &&
+		m.branch < len(m.branches) && !m.branches[m.branch].Active {
+		leaf = m.branches[m.branch].Leaf
+	}
+
+	m.selectedSession = sess
+	m.branches = sess.Tree.Branches()
+	m.branch = 0
+	for i, b := range m.branches {
+		if b.Leaf == leaf {
+			m.branch = i
+		}
+	}
+	m.showBranch()
+}
+
+// restoreCheckpoint writes the selected checkpoint's backups to disk and
+// reports the outcome in the checkpoint view.
+func (m *Model) restoreCheckpoint() {
+	if m.selectedSession == nil || m.checkpointCursor >= len(m.selectedSession.Checkpoints) {
+		return
+	}
+	cp := m.selectedSession.Checkpoints[m.checkpointCursor]
+	restored, err := cp.Restore(m.selectedSession.Info)
+	defer m.compareCheckpoint()
+	if err != nil {
+		m.restoreStatus = fmt.Sprintf("Restore failed after %d files: %v", len(restored), err)
+		return
+	}
+	m.restoreStatus = fmt.Sprintf("Restored %d files from the checkpoint.", len(restored))
+	if n := len(m.checkpointOutside); n > 0 {
+		m.restoreStatus += fmt.Sprintf(" Skipped %d outside the session's directories.", n)
+	}
+}
+
+// compareCheckpoint compares the open checkpoint's files with disk, once when
+// it is opened and again after a restore rather than on every render.
+func (m *Model) compareCheckpoint() {
+	m.checkpointCompared, m.checkpointOutside = nil, nil
+	if m.selectedSession == nil || m.checkpointCursor >= len(m.selectedSession.Checkpoints) {
+		return
+	}
+	cp := m.selectedSession.Checkpoints[m.checkpointCursor]
+	for _, f := range cp.Files {
+		m.checkpointCompared = append(m.checkpointCompared, f.Compare())
+	}
+	m.checkpointOutside = cp.Outside(m.selectedSession.Info)
+}
+
+// cursorEvent returns the event under the timeline cursor, or a zero Event.
+func (m Model) cursorEvent() session.Event {
+	if m.mode != viewDetail || m.selectedSession == nil || m.detailCursor >= len(m.timeline) {
+		return session.Event{}
+	}
+	return m.selectedSession.Events[m.timeline[m.detailCursor]]
+}
+
+// showBranch rebuilds the timeline for the selected branch.
+func (m *Model) showBranch() {
+	leaf := ""
+	if m.branch < len(m.branches) && !m.branches[m.branch].Active {
+		leaf = m.branches[m.branch].Leaf
+	}
+	m.timeline = m.selectedSession.Timeline(leaf)
+	if m.detailCursor >= len(m.timeline) {
+		m.detailCursor = max(0, len(m.timeline)-1)
+	}
+}
+
 func (m Model) loadSessions() tea.Msg {
 	return sessionsUpdatedMsg{}
 }
@@ -563,8 +1012,11 @@ func (m Model) watchForUpdates() tea.Msg {
 	if m.updates == nil {
 		return nil
 	}
-	<-m.updates
-	return sessionsUpdatedMsg{}
+	changes, ok := <-m.updates
+	if !ok {
+		return nil
+	}
+	return sessionsUpdatedMsg{changes: changes}
 }
 
 type helpKey struct {
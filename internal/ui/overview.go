@@ -26,32 +26,50 @@ func renderSessionOverview(sess *session.Session, todos []session.TodoItem, hasP
 	lines = append(lines, fieldLine("Session ID", info.ID))
 	lines = append(lines, fieldLine("Project", info.ProjectDir))
 	lines = append(lines, fieldLine("Working Dir", info.CWD))
+	if len(info.GitBranches) > 0 {
+		lines = append(lines, fieldLine("Git Branch", strings.Join(info.GitBranches, " → ")))
+	}
 	if info.Model != "" {
 		lines = append(lines, fieldLine("Model", info.Model))
 	}
+	if len(info.Versions) > 0 {
+		lines = append(lines, fieldLine("CLI Version", strings.Join(info.Versions, ", ")))
+	}
 	lines = append(lines, fieldLine("Started", info.StartTime.Format("2006-01-02 15:04:05")))
 	lines = append(lines, fieldLine("Last Active", info.LastUpdate.Format("2006-01-02 15:04:05")))
 	duration := info.LastUpdate.Sub(info.StartTime)
 	lines = append(lines, fieldLine("Duration", duration.Round(1e9).String()))
-	if info.IsAgent {
+	if info.IsAgent || info.IsSidechain {
 		lines = append(lines, fieldLine("Type", systemStyle.Render("Subagent")))
+		if info.ParentID != "" {
+			lines = append(lines, fieldLine("Spawned By", shortID(info.ParentID)))
+		}
 	}
 	lines = append(lines, "")
 
 	// Token breakdown
 	lines = append(lines, sectionHeader("Token Usage"))
-	totalTokens := info.InputTokens + info.OutputTokens + info.CacheReadTokens + info.CacheWriteTokens
+	// Totals include subagents spawned by this session
+	usage := info.Inclusive
+	totalTokens := usage.Tokens()
 	lines = append(lines, fieldLine("Total Tokens", tokenStyle.Render(formatTokensComma(totalTokens))))
-	lines = append(lines, fieldLine("  Input", formatTokensComma(info.InputTokens)))
-	lines = append(lines, fieldLine("  Output", formatTokensComma(info.OutputTokens)))
-	lines = append(lines, fieldLine("  Cache Read", formatTokensComma(info.CacheReadTokens)))
-	lines = append(lines, fieldLine("  Cache Write", formatTokensComma(info.CacheWriteTokens)))
-	lines = append(lines, fieldLine("API Equiv.", costStyle.Render(fmt.Sprintf("$%.4f", info.CostUSD))+dimStyle.Render(" (not actual cost on Max plan)")))
+	lines = append(lines, fieldLine("  Input", formatTokensComma(usage.InputTokens)))
+	lines = append(lines, fieldLine("  Output", formatTokensComma(usage.OutputTokens)))
+	lines = append(lines, fieldLine("  Cache Read", formatTokensComma(usage.CacheReadTokens)))
+	lines = append(lines, fieldLine("  Cache Write", formatTokensComma(usage.CacheWriteTokens)))
+	lines = append(lines, fieldLine("API Equiv.", costStyle.Render(formatCost(usage.CostUSD, usage.UnpricedModels))+dimStyle.Render(" (not actual cost on Max plan)")))
+	if own := info.Own(); own.Tokens() != totalTokens {
+		lines = append(lines, fieldLine("  Own", formatTokensComma(own.Tokens())+" tokens, "+formatCost(own.CostUSD, own.UnpricedModels)))
+		lines = append(lines, fieldLine("  Subagents", formatTokensComma(totalTokens-own.Tokens())+" tokens, "+formatCost(usage.CostUSD-own.CostUSD, nil)))
+	}
+	if len(usage.UnpricedModels) > 0 {
+		lines = append(lines, fieldLine("Unpriced", toolErrorStyle.Render(strings.Join(usage.UnpricedModels, ", "))+dimStyle.Render(" (not in cost)")))
+	}
 
 	// Token bar visualization
 	if totalTokens > 0 {
 		lines = append(lines, "")
-		lines = append(lines, renderTokenBar(info, min(width-6, 60)))
+		lines = append(lines, renderTokenBar(usage, min(width-6, 60)))
 	}
 	lines = append(lines, "")
 
@@ -114,6 +132,25 @@ func renderSessionOverview(sess *session.Session, todos []session.TodoItem, hasP
 		lines = append(lines, "")
 	}
 
+	// Files deleted
+	if len(info.FilesDeleted) > 0 {
+		lines = append(lines, sectionHeader(fmt.Sprintf("Files Deleted (%d)", len(info.FilesDeleted))))
+		sorted := sortedShortPaths(info.FilesDeleted, info.CWD)
+		for _, fp := range sorted {
+			lines = append(lines, dimStyle.Render("    ")+toolErrorStyle.Render(fp))
+		}
+		lines = append(lines, "")
+	}
+
+	// Problems found while parsing the session's file
+	if len(info.Diagnostics) > 0 {
+		lines = append(lines, sectionHeader(fmt.Sprintf("Parse Problems (%d)", len(info.Diagnostics))))
+		for _, d := range info.Diagnostics {
+			lines = append(lines, dimStyle.Render("    ")+toolErrorStyle.Render(d.String()))
+		}
+		lines = append(lines, "")
+	}
+
 	lines = append(lines, mutedStyle.Render(strings.Repeat("─", min(width, 60))))
 	lines = append(lines, dimStyle.Render("  Press ")+keyStyle.Render("enter")+dimStyle.Render(" or ")+keyStyle.Render("t")+dimStyle.Render(" to view event timeline"))
 	if hasProjectMemory {
@@ -145,8 +182,8 @@ func fieldLine(label, value string) string {
 	return fmt.Sprintf("    %s %s", dimStyle.Render(fmt.Sprintf("%-16s", label)), value)
 }
 
-func renderTokenBar(info session.SessionInfo, barWidth int) string {
-	total := info.InputTokens + info.OutputTokens + info.CacheReadTokens + info.CacheWriteTokens
+func renderTokenBar(info session.Usage, barWidth int) string {
+	total := info.Tokens()
 	if total == 0 || barWidth < 10 {
 		return ""
 	}
@@ -2,6 +2,8 @@ package ui
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/fooxytv/verbose/internal/session"
@@ -20,13 +22,23 @@ func renderProjectView(proj *session.ProjectInfo, scroll, cursor, width, height
 	// Stats section
 	lines = append(lines, sectionHeader("Stats"))
 	lines = append(lines, fieldLine("Sessions", fmt.Sprintf("%d", proj.TotalSessions)))
+	if len(proj.SessionsBySource) > 1 {
+		var agents []string
+		for _, name := range slices.Sorted(maps.Keys(proj.SessionsBySource)) {
+			agents = append(agents, fmt.Sprintf("%s %d", name, proj.SessionsBySource[name]))
+		}
+		lines = append(lines, fieldLine("  By Agent", strings.Join(agents, " · ")))
+	}
 	if !proj.FirstSession.IsZero() {
 		lines = append(lines, fieldLine("First Session", proj.FirstSession.Format("2006-01-02 15:04")))
 	}
 	if !proj.LastSession.IsZero() {
 		lines = append(lines, fieldLine("Last Session", proj.LastSession.Format("2006-01-02 15:04")))
 	}
-	lines = append(lines, fieldLine("Total Cost", costStyle.Render(fmt.Sprintf("$%.4f", proj.TotalCostUSD))))
+	lines = append(lines, fieldLine("Total Cost", costStyle.Render(formatCost(proj.TotalCostUSD, proj.UnpricedModels))))
+	if len(proj.UnpricedModels) > 0 {
+		lines = append(lines, fieldLine("Unpriced", toolErrorStyle.Render(strings.Join(proj.UnpricedModels, ", "))+dimStyle.Render(" (not in cost)")))
+	}
 
 	totalTokens := proj.TotalInputTokens + proj.TotalOutputTokens + proj.TotalCacheReadTokens + proj.TotalCacheWriteTokens
 	lines = append(lines, fieldLine("Total Tokens", tokenStyle.Render(formatTokensComma(totalTokens))))
@@ -37,7 +49,7 @@ func renderProjectView(proj *session.ProjectInfo, scroll, cursor, width, height
 
 	// Token bar
 	if totalTokens > 0 {
-		info := session.SessionInfo{
+		info := session.Usage{
 			InputTokens:      proj.TotalInputTokens,
 			OutputTokens:     proj.TotalOutputTokens,
 			CacheReadTokens:  proj.TotalCacheReadTokens,
@@ -9,19 +9,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// resumeSessionCmd returns a tea.Cmd that resumes a session.
+// resumeSessionCmd returns a tea.Cmd that runs a session's resume command,
+// as given by the store's source for it.
 // Detection priority: tmux (split pane) > iTerm2 (new tab) > Terminal.app > in-place.
-func resumeSessionCmd(sessionID, cwd string) tea.Cmd {
-	// Determine CLI command and actual session ID
-	cli := "claude"
-	resumeID := sessionID
-	resumeArgs := []string{"--resume", resumeID}
-
-	if strings.HasPrefix(sessionID, "oc-") {
-		cli = "opencode"
-		resumeID = strings.TrimPrefix(sessionID, "oc-")
-		resumeArgs = []string{"--resume", resumeID}
-	}
+func resumeSessionCmd(cli string, resumeArgs []string, sessionID, cwd string) tea.Cmd {
 
 	// If inside tmux, split the current window — stays in context.
 	if os.Getenv("TMUX") != "" {
@@ -18,11 +18,11 @@ func renderSessionsList(sessions []session.SessionInfo, cursor int, width, heigh
 	b.WriteString("\n")
 
 	// Column headers
-	cols := mutedStyle.Render(fmt.Sprintf("  %-4s  %-18s  %-10s  %7s  %9s  %8s  %5s  %5s",
-		"", "PROJECT", "SESSION", "AGO", "TOKENS", "COST", "TOOLS", "EDITS"))
+	cols := mutedStyle.Render(fmt.Sprintf("  %-4s  %-18s  %-16s  %-10s  %7s  %9s  %8s  %5s  %5s",
+		"", "PROJECT", "BRANCH", "SESSION", "AGO", "TOKENS", "COST", "TOOLS", "EDITS"))
 	b.WriteString(cols)
 	b.WriteString("\n")
-	b.WriteString(mutedStyle.Render(strings.Repeat("─", min(width, 90))))
+	b.WriteString(mutedStyle.Render(strings.Repeat("─", min(width, 108))))
 	b.WriteString("\n")
 
 	if len(sessions) == 0 {
@@ -74,14 +74,26 @@ func formatSessionLine(s session.SessionInfo, width int) string {
 	status := "●"
 	if s.Source == "opencode" {
 		status = "◈"
+	} else if s.Source == "codex" {
+		status = "◇"
+	} else if s.Source == "gemini" {
+		status = "✦"
+	} else if s.Source == "aider" {
+		status = "▪"
 	} else if s.IsAgent {
 		status = "◦"
+		if s.ParentID != "" {
+			status = "└◦"
+		}
+	}
+	if len(s.Diagnostics) > 0 {
+		// Parsed with problems, see the overview or `verbose doctor`
+		status += "!"
 	}
 
 	ago := timeAgo(s.LastUpdate)
-	totalTokens := s.InputTokens + s.OutputTokens + s.CacheReadTokens + s.CacheWriteTokens
-	tokenStr := formatTokens(totalTokens)
-	costStr := fmt.Sprintf("$%.4f", s.CostUSD)
+	tokenStr := formatTokens(s.Inclusive.Tokens())
+	costStr := formatCost(s.Inclusive.CostUSD, s.Inclusive.UnpricedModels)
 
 	shortID := s.ID
 	if len(shortID) > 10 {
@@ -93,11 +105,23 @@ func formatSessionLine(s session.SessionInfo, width int) string {
 		project = project[:15] + "..."
 	}
 
+	// Latest branch, marked when the session moved between branches
+	branch := "—"
+	if n := len(s.GitBranches); n > 0 {
+		branch = s.GitBranches[n-1]
+		if n > 1 {
+			branch += "+"
+		}
+	}
+	if len(branch) > 16 {
+		branch = branch[:13] + "..."
+	}
+
 	toolStr := fmt.Sprintf("%d", s.ToolCallCount)
 	editStr := fmt.Sprintf("%d", len(s.FilesWritten)+len(s.FilesCreated))
 
-	return fmt.Sprintf("%-4s  %-18s  %-10s  %7s  %9s  %8s  %5s  %5s",
-		status, project, shortID, ago, tokenStr, costStr, toolStr, editStr)
+	return fmt.Sprintf("%-4s  %-18s  %-16s  %-10s  %7s  %9s  %8s  %5s  %5s",
+		status, project, branch, shortID, ago, tokenStr, costStr, toolStr, editStr)
 }
 
 func timeAgo(t time.Time) string {
@@ -122,6 +146,16 @@ func timeAgo(t time.Time) string {
 	}
 }
 
+// formatCost renders a USD cost, marked with "?" when some of the usage came
+// from models without a known price and is therefore missing from the total.
+func formatCost(cost float64, unpriced []string) string {
+	s := fmt.Sprintf("$%.4f", cost)
+	if len(unpriced) > 0 {
+		s += "?"
+	}
+	return s
+}
+
 func formatTokens(n int) string {
 	if n < 1000 {
 		return fmt.Sprintf("%d", n)
@@ -24,29 +24,59 @@ func main() {
 
 	project := flag.String("project", "", "filter to a specific project name")
 	opencode := flag.String("opencode", "", "path to an OpenCode database (.opencode/opencode.db)")
+	reindex := flag.Bool("reindex", false, "rebuild the session index from scratch")
 	flag.Parse()
 
-	store, err := session.NewStore()
+	// doctor re-reads every file, so problems in indexed sessions are current,
+	// and leaves the index alone
+	doctor := flag.Arg(0) == "doctor"
+	var opts []session.StoreOption
+	if doctor {
+		opts = append(opts, session.WithoutIndex())
+	}
+
+	store, err := session.NewStore(opts...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing store: %v\n", err)
 		os.Exit(1)
 	}
 	defer store.Close()
 
+	if *reindex {
+		if err := store.RebuildIndex(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to rebuild session index: %v\n", err)
+		}
+	}
+
 	if *opencode != "" {
 		store.AddOpenCodeDB(*opencode)
 	}
 
 	// Initial scan of all sessions
-	if err := store.Scan(); err != nil {
+	if err := store.Scan(); err != nil && !doctor {
 		fmt.Fprintf(os.Stderr, "Warning: failed to scan sessions: %v\n", err)
 	}
 
-	// Start watching for file changes
-	updates := store.Watch()
+	pricingErr := session.PricingError()
+	if pricingErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring price overrides in %v\n", pricingErr)
+	}
+
+	if doctor {
+		if !printHealth(os.Stdout, store.Health()) || pricingErr != nil {
+			store.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Subscribe before watching, so no change the watchers find is missed
+	sub := store.Subscribe()
+	defer sub.Unsubscribe()
+	store.Watch()
 
 	model := ui.NewModel(store, *project, version)
-	model.SetUpdates(updates)
+	model.SetUpdates(sub.C)
 
 	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {